@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"os"
 
 	billing "cloud.google.com/go/billing/apiv1/billingpb"
@@ -25,9 +25,8 @@ func main() {
 		),
 	)
 	if err != nil {
-		log.Print(err)
-	}
-	if err := cmd.ExecuteContext(context.Background()); err != nil {
-		log.Print(err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(grpctl.ExitCode(err))
 	}
+	os.Exit(grpctl.ExitCode(cmd.ExecuteContext(context.Background())))
 }
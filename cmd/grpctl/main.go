@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"os"
 
 	"github.com/joshcarp/grpctl"
 )
@@ -10,9 +11,9 @@ import (
 func main() {
 	cmd, err := grpctl.ReflectionCommand()
 	if err != nil {
-		log.Fatal(err)
-	}
-	if err := cmd.ExecuteContext(context.Background()); err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(grpctl.ExitCode(err))
 	}
+	// Errors of commands are printed by cobra.
+	os.Exit(grpctl.ExitCode(cmd.ExecuteContext(context.Background())))
 }
@@ -3,6 +3,7 @@ package grpctl
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
@@ -11,10 +12,14 @@ import (
 	"google.golang.org/grpc/metadata"
 
 	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/joshcarp/grpctl/internal/descriptors"
+	"github.com/joshcarp/grpctl/internal/expand"
+	"github.com/joshcarp/grpctl/internal/output"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
@@ -29,13 +34,25 @@ func WithStdin(stdin io.Reader) func(cmd *cobra.Command) error {
 }
 
 // BuildCommand builds a grpctl command from a list of GrpctlOption.
+// Commands are generated from descriptors after all options are applied, so the order of the options doesn't matter.
 func BuildCommand(cmd *cobra.Command, opts ...CommandOption) error {
+	s := getSettings(cmd)
+	s.building = true
+	defer func() {
+		s.building, s.pending = false, nil
+	}()
 	for _, f := range opts {
 		err := f(cmd)
 		if err != nil {
 			return err
 		}
 	}
+	s.building = false
+	for _, f := range s.pending {
+		if err := f(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -86,13 +103,7 @@ func CommandFromFileDescriptors(cmd *cobra.Command, descriptors ...protoreflect.
 
 // CommandFromFileDescriptor adds commands to cmd from a single FileDescriptor.
 func CommandFromFileDescriptor(cmd *cobra.Command, methods protoreflect.FileDescriptor) error {
-	seen := map[string]bool{}
 	for _, service := range descriptors.ServicesFromFileDescriptor(methods) {
-		command := descriptors.Command(service)
-		if seen[command] {
-			return fmt.Errorf("duplicate service name: %s in %s", command, methods.Name())
-		}
-		seen[command] = true
 		err := CommandFromServiceDescriptor(cmd, service)
 		if err != nil {
 			return err
@@ -104,25 +115,54 @@ func CommandFromFileDescriptor(cmd *cobra.Command, methods protoreflect.FileDesc
 // CommandFromServiceDescriptor adds commands to cmd from a ServiceDescriptor.
 // Commands added through this will have two levels: the ServiceDescriptor name as level 1 commands
 // And the MethodDescriptors as level 2 commands.
+// With QualifiedNames the ServiceDescriptor commands are nested under a command for every segment of their package.
 func CommandFromServiceDescriptor(cmd *cobra.Command, service protoreflect.ServiceDescriptor) error {
-	command := descriptors.Command(service)
-	serviceCmd := cobra.Command{
-		Use:   command,
-		Short: fmt.Sprintf("%s as defined in %s", command, service.ParentFile().Path()),
+	if descriptors.Deprecated(service) && getSettings(cmd).hideDeprecated {
+		return nil
 	}
-	for _, method := range descriptors.MethodsFromServiceDescriptor(service) {
-		err := CommandFromMethodDescriptor(&serviceCmd, method)
-		if err != nil {
-			return err
+	serviceCmd := newServiceCommand(cmd, service)
+	added, err := addServiceCommand(cmd, serviceCmd, service)
+	if err != nil || !added {
+		return err
+	}
+	if err := addMethodCommands(serviceCmd, service); err != nil {
+		return err
+	}
+	if getSettings(cmd).packageNaming == QualifiedNames && service.ParentFile().Package() != "" {
+		shortCmd := newServiceCommand(cmd, service)
+		if addShortForm(cmd, shortCmd, service) {
+			if err := addMethodCommands(shortCmd, service); err != nil {
+				return err
+			}
 		}
 	}
-	cmd.AddCommand(&serviceCmd)
 	defaulthost := proto.GetExtension(service.Options(), annotations.E_DefaultHost)
-	serviceCmd.Parent().ResetFlags()
+	cmd.ResetFlags()
 	if defaulthost != "" {
-		return persistentFlags(serviceCmd.Parent(), fmt.Sprintf("%v:443", defaulthost))
+		return persistentFlags(cmd, fmt.Sprintf("%v:443", defaulthost))
+	}
+	return persistentFlags(cmd)
+}
+
+func newServiceCommand(cmd *cobra.Command, service protoreflect.ServiceDescriptor) *cobra.Command {
+	command := getSettings(cmd).naming.command(service)
+	short := fmt.Sprintf("%s as defined in %s", service.Name(), service.ParentFile().Path())
+	return &cobra.Command{
+		Use:        command,
+		Aliases:    aliases(string(service.Name()), command),
+		Short:      summary(service, short),
+		Long:       long(service, short),
+		Deprecated: deprecated(service),
+	}
+}
+
+func addMethodCommands(serviceCmd *cobra.Command, service protoreflect.ServiceDescriptor) error {
+	for _, method := range descriptors.MethodsFromServiceDescriptor(service) {
+		if err := CommandFromMethodDescriptor(serviceCmd, method); err != nil {
+			return err
+		}
 	}
-	return persistentFlags(serviceCmd.Parent())
+	return nil
 }
 
 func endpointType(method protoreflect.MethodDescriptor) string {
@@ -138,73 +178,81 @@ func endpointType(method protoreflect.MethodDescriptor) string {
 	return "Unary"
 }
 
+// summary returns the first line of the descriptor's comments, or fallback if it has none.
+func summary(descriptor protoreflect.Descriptor, fallback string) string {
+	if s := descriptors.Summary(descriptor); s != "" {
+		return s
+	}
+	return fallback
+}
+
+// long returns the full comments of the descriptor followed by generated, or an empty string if it has none.
+func long(descriptor protoreflect.Descriptor, generated string) string {
+	leading, trailing := descriptors.Comments(descriptor)
+	var parts []string
+	for _, comment := range []string{leading, trailing} {
+		if comment != "" {
+			parts = append(parts, comment)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(append(parts, generated), "\n\n")
+}
+
+// deprecated returns the message shown when a deprecated descriptor is used, or an empty string if it isn't deprecated.
+func deprecated(descriptor protoreflect.Descriptor) string {
+	if !descriptors.Deprecated(descriptor) {
+		return ""
+	}
+	return fmt.Sprintf("%s is marked as deprecated in %s", descriptor.FullName(), descriptor.ParentFile().Path())
+}
+
+// methodDeprecated returns the deprecation message of a method, which is also deprecated if its service is.
+func methodDeprecated(method protoreflect.MethodDescriptor) string {
+	if msg := deprecated(method); msg != "" {
+		return msg
+	}
+	return deprecated(method.Parent())
+}
+
 // CommandFromMethodDescriptor adds commands to cmd from a MethodDescriptor.
 // Commands added through this will have one level from the MethodDescriptors name.
 func CommandFromMethodDescriptor(cmd *cobra.Command, method protoreflect.MethodDescriptor) error {
-	dataMap := make(descriptors.DataMap)
-	for fieldNum := 0; fieldNum < method.Input().Fields().Len(); fieldNum++ {
-		field := method.Input().Fields().Get(fieldNum)
-		jsonName := field.JSONName()
-		field.Default()
-		field.Kind()
-		dataMap[jsonName] = &descriptors.DataValue{Kind: field.Kind(), Value: field.Default().Interface(), Proto: true}
-	}
-	var inputData, data string
-	methodCmdName := descriptors.Command(method)
+	settings := getSettings(cmd)
+	if settings.hideDeprecated && descriptors.Deprecated(method) {
+		return nil
+	}
+	flags := newFlagBuilder(settings)
+	flags.addMessage(method.Input(), "", nil, []protoreflect.MessageDescriptor{method.Input()}, true)
+	r := &methodRunner{
+		method:    method,
+		settings:  settings,
+		flags:     flags,
+		out:       outputFlags{format: settings.output, marshal: settings.marshal, color: "auto", chosen: settings.output != ""},
+		unmarshal: settings.unmarshal,
+	}
+	if r.out.format == "" {
+		r.out.format = string(output.JSON)
+	}
+	methodCmdName := settings.naming.command(method)
+	short := fmt.Sprintf("%s (%s) as defined in %s", method.Name(), endpointType(method), method.ParentFile().Path())
 	methodCmd := cobra.Command{
-		Use:   methodCmdName,
-		Short: fmt.Sprintf("%s (%s) as defined in %s", methodCmdName, endpointType(method), method.ParentFile().Path()),
+		Use:        methodCmdName,
+		Aliases:    aliases(string(method.Name()), methodCmdName),
+		Short:      summary(method, short),
+		Long:       long(method, short),
+		Deprecated: methodDeprecated(method),
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			cmd.Root().SetContext(context.WithValue(cmd.Root().Context(), methodDescriptorKey{}, method))
 			return recusiveParentPreRun(cmd.Parent(), args)
 		},
-		RunE: func(cmd *cobra.Command, args []string) error {
-			protocol, err := cmd.Flags().GetString("protocol")
-			if err != nil {
-				return err
-			}
-			http1, err := cmd.Flags().GetBool("http1")
-			if err != nil {
-				return err
-			}
-			headers, err := cmd.Flags().GetStringArray("header")
-			if err != nil {
-				return err
-			}
-			addr, err := cmd.Flags().GetString("address")
-			if err != nil {
-				return err
-			}
-			if addr == "" {
-				return nil
-			}
-			for _, header := range headers {
-				keyval := strings.Split(header, ":")
-				if len(keyval) != 2 {
-					return fmt.Errorf("headers need to be in form -H=Foo:Bar")
-				}
-				cmd.Root().SetContext(metadata.AppendToOutgoingContext(cmd.Root().Context(), keyval[0], strings.TrimLeft(keyval[1], " ")))
-			}
-			if err != nil {
-				return err
-			}
-			switch data {
-			case "":
-				b, err := dataMap.ToJSON()
-				if err != nil {
-					return err
-				}
-				inputData = string(b)
-			default:
-				inputData = data
-			}
-			if method.IsStreamingClient() || method.IsStreamingServer() {
-				return handleStreaming(cmd, method, addr, protocol, http1)
-			}
-			return handleUnary(cmd, addr, method, inputData, protocol, http1)
-		},
+		RunE: r.run,
+	}
+	if err := r.addFlags(&methodCmd); err != nil {
+		return err
 	}
-	methodCmd.Flags().StringVar(&data, "json-data", "", "JSON data input that will be used as a request")
 	defaults, templ := descriptors.MakeJSONTemplate(method.Input())
 	err := methodCmd.RegisterFlagCompletionFunc("json-data", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
 		return []string{templ}, cobra.ShellCompDirectiveDefault
@@ -212,75 +260,592 @@ func CommandFromMethodDescriptor(cmd *cobra.Command, method protoreflect.MethodD
 	if err != nil {
 		return err
 	}
-	for key, val := range dataMap {
-		methodCmd.Flags().Var(val, key, "")
-		err := methodCmd.RegisterFlagCompletionFunc(key, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
-			return []string{fmt.Sprintf("%v", defaults[key])}, cobra.ShellCompDirectiveDefault
-		})
+	if shadowed := flags.removeShadowed(methodCmd.Flags()); len(shadowed) > 0 {
+		if methodCmd.Long == "" {
+			methodCmd.Long = methodCmd.Short
+		}
+		methodCmd.Long += fmt.Sprintf("\n\nThe field(s) %s have no flag, as they have the name of a flag of the command. Set them with --json-data.",
+			strings.Join(shadowed, ", "))
+	}
+	for key, val := range flags.dataMap {
+		val.Stdin = methodCmd.InOrStdin
+		methodCmd.Flags().Var(val, key, val.Usage)
+		if val.Deprecated != "" {
+			if err := methodCmd.Flags().MarkDeprecated(key, val.Deprecated); err != nil {
+				return err
+			}
+		}
+		err := methodCmd.RegisterFlagCompletionFunc(key, flagCompletion(val, defaults))
 		if err != nil {
 			return err
 		}
 	}
+	for _, group := range flags.exclusive {
+		methodCmd.MarkFlagsMutuallyExclusive(group...)
+	}
+	methodCmd.Flags().SetNormalizeFunc(normalizeFlags(flags.aliases))
 	methodCmd.ValidArgsFunction = cobra.NoFileCompletions
 	cmd.AddCommand(&methodCmd)
 	return nil
 }
 
-func handleUnary(cmd *cobra.Command, addr string, method protoreflect.MethodDescriptor, inputData string, protocol string, http1 bool) error {
-	marshallerm, err := grpc.CallUnary(cmd.Root().Context(), addr, method, []byte(inputData), protocol, http1)
+// methodRunner holds the flags of a method command, other than those of the request's fields, and runs it.
+type methodRunner struct {
+	method    protoreflect.MethodDescriptor
+	settings  *settings
+	flags     *flagBuilder
+	input     inputFlags
+	out       outputFlags
+	unmarshal protojson.UnmarshalOptions
+
+	skipValidation, showRequest, interactive, edit, example, allowPartial bool
+}
+
+// addFlags adds the flags of the runner to methodCmd.
+func (r *methodRunner) addFlags(methodCmd *cobra.Command) error {
+	methodCmd.Flags().StringVar(&r.input.json, "json-data", "", "JSON data input that will be used as a request, or @path of a file or - for stdin")
+	methodCmd.Flags().StringVar(&r.input.yaml, "yaml-data", "", "YAML data input that will be used as a request, or @path of a file or - for stdin")
+	methodCmd.Flags().StringVar(&r.input.text, "text-data", "", "protobuf text format input that will be used as a request, or @path of a file or - for stdin")
+	methodCmd.MarkFlagsMutuallyExclusive("json-data", "yaml-data", "text-data")
+	methodCmd.Flags().StringVar(&r.input.vars, "vars", "", "YAML or JSON file of variables for the ${NAME} and {{ .NAME }} placeholders of the request and headers")
+	methodCmd.Flags().BoolVar(&r.input.expand, "expand", false, "expand the ${NAME} and {{ }} placeholders of the request and headers, which --vars implies")
+	methodCmd.Flags().BoolVar(&r.showRequest, "show-request", false, "print the request to stderr before it is sent")
+	methodCmd.Flags().BoolVarP(&r.interactive, "interactive", "i", false, "prompt for the fields of the request, starting from the values given by flags")
+	methodCmd.Flags().BoolVar(&r.edit, "edit", false, "open the request, or a template of it if no field is set, in $EDITOR before it is sent")
+	methodCmd.MarkFlagsMutuallyExclusive("interactive", "edit")
+	methodCmd.Flags().BoolVar(&r.example, "example", false, "print an example request with every field set in the --output format, instead of sending a request")
+	methodCmd.Flags().StringVarP(&r.out.format, "output", "o", r.out.format, "format of the responses: "+strings.Join(output.Formats(), ", "))
+	methodCmd.Flags().StringVar(&r.out.jq, "jq", "", "jq expression that filters the responses, whose results are written as JSON or YAML")
+	methodCmd.Flags().StringVar(&r.out.template, "template", "", "Go template that formats the responses, eg '{{ .name }}'")
+	methodCmd.MarkFlagsMutuallyExclusive("jq", "template")
+	methodCmd.Flags().StringSliceVar(&r.out.columns, "columns", nil, "fields shown by --output table, eg name,state,createTime, of the elements of List responses")
+	methodCmd.Flags().BoolVarP(&r.out.raw, "raw-output", "r", false, "write strings returned by --jq without quotes")
+	methodCmd.Flags().StringVar(&r.out.color, "color", r.out.color, "color JSON and YAML responses: "+strings.Join(colorModes(), ", ")+", where auto is on terminals without NO_COLOR")
+	methodCmd.Flags().BoolVar(&r.out.noPager, "no-pager", false, "don't page responses that are taller than the terminal with $PAGER")
+	methodCmd.Flags().BoolVar(&r.out.marshal.EmitUnpopulated, "emit-defaults", r.out.marshal.EmitUnpopulated, "write unset fields of responses with their default value")
+	methodCmd.Flags().BoolVar(&r.out.marshal.UseProtoNames, "use-proto-names", r.out.marshal.UseProtoNames,
+		"write the proto names of fields, eg create_time, instead of their JSON names")
+	methodCmd.Flags().BoolVar(&r.out.marshal.UseEnumNumbers, "enums-as-ints", r.out.marshal.UseEnumNumbers, "write enum values as numbers instead of their names")
+	methodCmd.Flags().BoolVar(&r.unmarshal.DiscardUnknown, "discard-unknown", r.unmarshal.DiscardUnknown, "ignore unknown fields of JSON requests instead of failing")
+	methodCmd.Flags().BoolVar(&r.allowPartial, "allow-partial", r.unmarshal.AllowPartial, "send requests and write responses that are missing proto2 required fields")
+	methodCmd.Flags().BoolVar(&r.skipValidation, "skip-validation", false, "send the request without checking its protovalidate or protoc-gen-validate constraints")
+	err := methodCmd.RegisterFlagCompletionFunc("output", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return output.Formats(), cobra.ShellCompDirectiveNoFileComp
+	})
 	if err != nil {
 		return err
 	}
-	res := string(marshallerm)
+	err = methodCmd.RegisterFlagCompletionFunc("columns", columnsCompletion(r.method.Output()))
 	if err != nil {
-		_, err = cmd.OutOrStderr().Write([]byte(err.Error()))
-		if err != nil {
+		return err
+	}
+	return methodCmd.RegisterFlagCompletionFunc("color", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return colorModes(), cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+func (r *methodRunner) streaming() bool {
+	return r.method.IsStreamingClient() || r.method.IsStreamingServer()
+}
+
+// run builds the request of the method from the flags and sends it, or only prints it if --address isn't set.
+func (r *methodRunner) run(cmd *cobra.Command, _ []string) error {
+	warnDeprecatedFlags(cmd)
+	if cmd.Flags().Changed("allow-partial") {
+		r.out.marshal.AllowPartial, r.unmarshal.AllowPartial = r.allowPartial, r.allowPartial
+	}
+	printer, flush, err := r.out.printer(cmd, r.method, r.example || !r.streaming())
+	if err != nil {
+		return err
+	}
+	if r.example {
+		if err := printer.Print(descriptors.MakeTemplate(r.method.Input(), nil)); err != nil {
+			return err
+		}
+		if err := printer.Close(); err != nil {
 			return err
 		}
+		return flush()
+	}
+	messages, err := r.input.readMessages(cmd, r.method)
+	if err != nil {
+		return err
+	}
+	addr, err := cmd.Flags().GetString("address")
+	if err != nil {
+		return err
+	}
+	var inputData string
+	var send bool
+	if r.streaming() {
+		messages, send, err = r.buildStreamingRequests(cmd, messages, addr != "")
+	} else {
+		inputData, send, err = r.buildUnaryRequest(cmd, messages)
+	}
+	if err != nil || !send || addr == "" {
 		return err
 	}
-	_, err = cmd.OutOrStdout().Write([]byte(res))
-	return err
+	if err := r.applyHeaders(cmd); err != nil {
+		return err
+	}
+	return r.send(cmd, addr, inputData, messages, printer, flush)
 }
 
-func handleStreaming(cmd *cobra.Command, method protoreflect.MethodDescriptor, addr, protocol string, http1 bool) (err error) {
-	inputJSON, outputJSON := make(chan []byte), make(chan []byte)
-	go func() {
-		reterr := grpc.CallStreaming(cmd.Root().Context(), addr, method, protocol, http1, inputJSON, outputJSON)
-		if reterr != nil {
-			err = reterr
-			return
+// buildUnaryRequest merges the messages of the input flags with the field flags, lets the user complete the request
+// with --interactive or --edit, and returns it as JSON. It returns false if the user cancelled the request.
+func (r *methodRunner) buildUnaryRequest(cmd *cobra.Command, messages []map[string]interface{}) (string, bool, error) {
+	request, err := buildRequest(r.method, messages, r.flags.dataMap)
+	if err != nil {
+		return "", false, err
+	}
+	send := true
+	switch {
+	case r.interactive:
+		request, send, err = newPrompter(cmd.InOrStdin(), cmd.ErrOrStderr(), r.settings).buildRequest(r.method.Input(), request)
+	case r.edit:
+		request, send, err = editRequest(cmd, r.method, r.flags, request, r.unmarshal, !r.skipValidation)
+	}
+	if err != nil || !send {
+		return "", false, err
+	}
+	if err := checkRequired(r.flags, request); err != nil {
+		return "", false, err
+	}
+	b, err := json.Marshal(request)
+	if err != nil {
+		return "", false, err
+	}
+	if err := r.printRequest(cmd, request); err != nil {
+		return "", false, err
+	}
+	return string(b), true, nil
+}
+
+// buildStreamingRequests returns the requests of a streaming method, see streamingRequests. It returns false without
+// reading them if they are neither sent, as calling isn't set, nor printed with --show-request.
+func (r *methodRunner) buildStreamingRequests(
+	cmd *cobra.Command, messages []map[string]interface{}, calling bool,
+) ([]map[string]interface{}, bool, error) {
+	if r.interactive || r.edit {
+		flag := "--interactive"
+		if r.edit {
+			flag = "--edit"
 		}
-	}()
-	b, err := io.ReadAll(cmd.InOrStdin())
+		return nil, false, fmt.Errorf("%s is not supported for %s methods", flag, strings.ToLower(endpointType(r.method)))
+	}
+	if !calling && !r.showRequest {
+		return nil, false, nil
+	}
+	messages, err := r.streamingRequests(cmd, messages)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, msg := range messages {
+		if err := r.printRequest(cmd, msg); err != nil {
+			return nil, false, err
+		}
+	}
+	return messages, true, nil
+}
+
+// printRequest writes request to stderr if --show-request is set.
+func (r *methodRunner) printRequest(cmd *cobra.Command, request map[string]interface{}) error {
+	if !r.showRequest {
+		return nil
+	}
+	b, err := json.MarshalIndent(request, "", " ")
 	if err != nil {
 		return err
 	}
-	msgArr := make([]map[string]any, 0)
-	if err := json.Unmarshal(b, &msgArr); err != nil {
+	fmt.Fprintln(cmd.ErrOrStderr(), string(b))
+	return nil
+}
+
+// applyHeaders adds the --header flags, with their placeholders expanded if requested, to the metadata of the root
+// context.
+func (r *methodRunner) applyHeaders(cmd *cobra.Command) error {
+	headers, err := cmd.Flags().GetStringArray("header")
+	if err != nil {
+		return err
+	}
+	vars, err := r.input.readVars()
+	if err != nil {
+		return err
+	}
+	for _, header := range headers {
+		if r.input.expanding() {
+			expanded, err := expand.String(header, vars)
+			if err != nil {
+				return fmt.Errorf("invalid header %q: %w", header, err)
+			}
+			header = expanded
+		}
+		keyval := strings.SplitN(header, ":", 2)
+		if len(keyval) != 2 {
+			return fmt.Errorf("headers need to be in form -H=Foo:Bar")
+		}
+		cmd.Root().SetContext(metadata.AppendToOutgoingContext(cmd.Root().Context(), keyval[0], strings.TrimLeft(keyval[1], " ")))
+	}
+	return nil
+}
+
+// send calls the method at addr with the request of a unary method, given as JSON, or the messages of a streaming
+// method, and prints its responses.
+func (r *methodRunner) send(
+	cmd *cobra.Command, addr, inputData string, messages []map[string]interface{}, printer *output.Printer, flush func() error,
+) error {
+	protocol, err := cmd.Flags().GetString("protocol")
+	if err != nil {
 		return err
 	}
-	for _, msg := range msgArr {
-		byteMsg, err := json.Marshal(msg)
+	http1, err := cmd.Flags().GetBool("http1")
+	if err != nil {
+		return err
+	}
+	if !r.streaming() {
+		if err := handleUnary(cmd, addr, r.method, inputData, protocol, http1, !r.skipValidation, r.unmarshal, printer); err != nil {
+			return r.out.callError(cmd, r.method, err)
+		}
+		return flush()
+	}
+	err = handleStreaming(cmd, r.method, addr, protocol, http1, !r.skipValidation, r.unmarshal, printer, messages)
+	return r.out.callError(cmd, r.method, err)
+}
+
+// inputFlags are the flags that give the request messages of a method command, each in another format.
+// If expand or vars is set, the placeholders in them are expanded with the variables of the file given by vars.
+type inputFlags struct {
+	json, yaml, text, vars string
+	expand                 bool
+}
+
+// expanding returns whether placeholders are expanded. Requests are sent as they are by default, as they may contain
+// text such as ${PRICE} or {{ }} that isn't a placeholder.
+func (f inputFlags) expanding() bool {
+	return f.expand || f.vars != ""
+}
+
+// readVars reads the variables of --vars, or returns nil if it isn't set.
+func (f inputFlags) readVars() (expand.Vars, error) {
+	if f.vars == "" {
+		return nil, nil
+	}
+	vars, err := expand.ReadVars(f.vars)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --vars: %w", err)
+	}
+	return vars, nil
+}
+
+// readMessages reads the messages given by --json-data, --yaml-data or --text-data, or returns nil if none is set.
+// JSON can be an array of messages and YAML can have multiple documents. Text has a message per line for
+// client streaming methods, for other methods the whole text is a single message.
+func (f inputFlags) readMessages(cmd *cobra.Command, method protoreflect.MethodDescriptor) ([]map[string]interface{}, error) {
+	flag, val := "json-data", f.json
+	switch {
+	case f.yaml != "":
+		flag, val = "yaml-data", f.yaml
+	case f.text != "":
+		flag, val = "text-data", f.text
+	case f.json == "":
+		return nil, nil
+	}
+	b, err := descriptors.ReadData(val, cmd.InOrStdin())
+	if err != nil {
+		return nil, err
+	}
+	if f.expanding() {
+		vars, err := f.readVars()
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if b, err = expand.Expand(b, vars); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flag, err)
+		}
+	}
+	var messages []map[string]interface{}
+	switch flag {
+	case "json-data":
+		messages, err = descriptors.JSONMessages(b)
+	case "yaml-data":
+		messages, err = descriptors.YAMLMessages(b)
+	case "text-data":
+		messages, err = textMessages(method, b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %w", flag, err)
+	}
+	for _, msg := range messages {
+		if err := descriptors.ExpandMessageBytesFiles(method.Input(), msg); err != nil {
+			return nil, fmt.Errorf("invalid --%s: %w", flag, err)
 		}
-		inputJSON <- byteMsg
 	}
-	close(inputJSON)
-	for marshallerm := range outputJSON {
-		res := string(marshallerm)
+	return messages, nil
+}
+
+func textMessages(method protoreflect.MethodDescriptor, b []byte) ([]map[string]interface{}, error) {
+	texts := []string{string(b)}
+	if method.IsStreamingClient() {
+		texts = strings.Split(string(b), "\n")
+	}
+	resolver, err := grpc.NewResolver(method.ParentFile())
+	if err != nil {
+		return nil, err
+	}
+	var messages []map[string]interface{}
+	for _, text := range texts {
+		if strings.TrimSpace(text) == "" && len(texts) > 1 {
+			continue
+		}
+		b, err := grpc.ParseText([]byte(text), method.Input(), resolver)
 		if err != nil {
-			_, err = cmd.OutOrStderr().Write([]byte(err.Error()))
-			if err != nil {
-				return err
+			return nil, err
+		}
+		msg, err := descriptors.UnmarshalJSON(b)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// streamingRequests returns the requests of a streaming method, with the field flags merged into each of them and
+// their required fields checked. Without an input flag, the requests are read from stdin as JSON, unless field flags
+// make the single request of a server streaming method.
+func (r *methodRunner) streamingRequests(cmd *cobra.Command, messages []map[string]interface{}) ([]map[string]interface{}, error) {
+	fields := r.flags.dataMap.ToInterfaceMap()
+	if messages == nil {
+		if !r.method.IsStreamingClient() && len(fields) > 0 {
+			messages = []map[string]interface{}{nil}
+		} else {
+			r.input.json = "-"
+			var err error
+			if messages, err = r.input.readMessages(cmd, r.method); err != nil {
+				return nil, err
 			}
-			return err
 		}
-		_, err = cmd.OutOrStdout().Write([]byte(res))
+	}
+	for i, msg := range messages {
+		messages[i] = descriptors.MergeJSON(r.method.Input(), msg, fields)
+		if err := checkRequired(r.flags, messages[i]); err != nil {
+			if len(messages) > 1 {
+				return nil, fmt.Errorf("request %d: %w", i+1, err)
+			}
+			return nil, err
+		}
+	}
+	return messages, nil
+}
+
+// buildRequest merges the values of the field flags into the request given by an input flag.
+func buildRequest(method protoreflect.MethodDescriptor, messages []map[string]interface{}, dataMap descriptors.DataMap) (map[string]interface{}, error) {
+	var request map[string]interface{}
+	switch len(messages) {
+	case 0:
+	case 1:
+		request = messages[0]
+	default:
+		return nil, fmt.Errorf("%s takes a single request, got %d", method.Name(), len(messages))
+	}
+	return descriptors.MergeJSON(method.Input(), request, dataMap.ToInterfaceMap()), nil
+}
+
+// checkRequired returns an error naming the flags of required fields that are set neither by a flag nor by --json-data.
+func checkRequired(flags *flagBuilder, request map[string]interface{}) error {
+	missing := flags.missingRequired(request)
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf(`required flag(s) "%s" not set`, strings.Join(missing, `", "`))
+}
+
+// warnDeprecatedFlags prints a warning for every deprecated flag that has been set.
+// pflag only writes these warnings into cobra's flag error buffer, which is discarded unless parsing fails.
+func warnDeprecatedFlags(cmd *cobra.Command) {
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if flag.Deprecated != "" {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Flag --%s has been deprecated, %s\n", flag.Name, flag.Deprecated)
+		}
+	})
+}
+
+func handleUnary(
+	cmd *cobra.Command,
+	addr string,
+	method protoreflect.MethodDescriptor,
+	inputData string,
+	protocol string,
+	http1, validateRequest bool,
+	unmarshal protojson.UnmarshalOptions,
+	printer *output.Printer,
+) error {
+	response, err := grpc.CallUnary(cmd.Root().Context(), addr, method, []byte(inputData), protocol, http1, validateRequest, unmarshal)
+	if err != nil {
+		return err
+	}
+	if err := printer.Print(response); err != nil {
+		return err
+	}
+	return printer.Close()
+}
+
+func handleStreaming(
+	cmd *cobra.Command,
+	method protoreflect.MethodDescriptor,
+	addr, protocol string,
+	http1, validateRequest bool,
+	unmarshal protojson.UnmarshalOptions,
+	printer *output.Printer,
+	messages []map[string]interface{},
+) error {
+	printer.Stream = true
+	requests := make([][]byte, 0, len(messages))
+	for _, msg := range messages {
+		b, err := json.Marshal(msg)
 		if err != nil {
 			return err
 		}
+		requests = append(requests, b)
+	}
+	ctx, cancel := context.WithCancel(cmd.Root().Context())
+	defer cancel()
+	inputJSON, responses, errc := make(chan []byte), make(chan proto.Message), make(chan error, 1)
+	go func() {
+		errc <- grpc.CallStreaming(ctx, addr, method, protocol, http1, validateRequest, unmarshal, inputJSON, responses)
+	}()
+	go func() {
+		defer close(inputJSON)
+		for _, b := range requests {
+			// The call can end before it has read every request.
+			select {
+			case inputJSON <- b:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for response := range responses {
+		if err := printer.Print(response); err != nil {
+			return err
+		}
+	}
+	if err := <-errc; err != nil {
+		return err
+	}
+	return printer.Close()
+}
+
+// outputFlags are the flags that choose how responses are written.
+type outputFlags struct {
+	format, jq, template string
+	raw                  bool
+	columns              []string
+	marshal              protojson.MarshalOptions
+	color                string
+	noPager              bool
+	// chosen is set if the output format was chosen with WithOutputFormat, rather than being the default.
+	chosen bool
+}
+
+// printer returns a printer of the method's responses to the command's output, and a function that must be called
+// after the printer is closed. If page is set and the output is taller than the terminal, the function writes it
+// through $PAGER.
+func (f outputFlags) printer(cmd *cobra.Command, method protoreflect.MethodDescriptor, page bool) (*output.Printer, func() error, error) {
+	format, err := output.ParseFormat(f.format)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --output: %w", err)
+	}
+	color, err := useColor(f.color, cmd.OutOrStdout())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --color: %w", err)
+	}
+	resolver, err := grpc.NewResolver(method.ParentFile())
+	if err != nil {
+		return nil, nil, err
+	}
+	options := f.marshal
+	options.Resolver = resolver
+	w, flush := cmd.OutOrStdout(), func() error { return nil }
+	width, height := terminalSize(w)
+	if command := pagerCommand(); page && !f.noPager && height > 0 && command != "" && format != output.Binary {
+		p := &pager{out: w, command: command, height: height}
+		w, flush = p, func() error { return p.Close(cmd) }
+	}
+	printer := output.NewPrinter(w, format, options)
+	printer.Color = color
+	switch {
+	case format == output.Table:
+		if err := printer.SetColumns(method.Output(), f.columns); err != nil {
+			return nil, nil, fmt.Errorf("invalid --columns: %w", err)
+		}
+		printer.Width = width
+	case len(f.columns) > 0:
+		return nil, nil, fmt.Errorf("--columns can only be used with --output table")
+	}
+	var filter *output.Filter
+	flag := "jq"
+	switch {
+	case f.jq != "":
+		filter, err = output.ParseQuery(f.jq)
+	case f.template != "":
+		flag = "template"
+		filter, err = output.ParseTemplate(f.template)
+	default:
+		return printer, flush, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --%s: %w", flag, err)
+	}
+	filter.Raw = f.raw
+	if err := printer.SetFilter(filter); err != nil {
+		return nil, nil, fmt.Errorf("--%s: %w", flag, err)
+	}
+	return printer, flush, nil
+}
+
+// callError decodes the details of the status of a failed call. If the output format was chosen with --output or
+// WithOutputFormat, and isn't table or binary, the status is written to stderr in that format instead of being
+// printed as an error by cobra.
+func (f outputFlags) callError(cmd *cobra.Command, method protoreflect.MethodDescriptor, err error) error {
+	if err == nil {
+		return nil
+	}
+	// The call failed after its flags were accepted, so the usage wouldn't help.
+	cmd.SilenceUsage = true
+	var statusErr *grpc.StatusError
+	if !errors.As(grpc.DecodeStatus(err, method.ParentFile()), &statusErr) {
+		return err
+	}
+	if !f.chosen && !cmd.Flags().Changed("output") {
+		return statusErr
+	}
+	format, formatErr := output.ParseFormat(f.format)
+	if formatErr != nil || format == output.Table || format == output.Binary {
+		return statusErr
+	}
+	options := f.marshal
+	options.Resolver = statusErr.Resolver()
+	b, marshalErr := output.Marshal(statusErr.Status(), format, options)
+	if marshalErr != nil {
+		return statusErr
+	}
+	fmt.Fprintln(cmd.ErrOrStderr(), strings.TrimSuffix(string(b), "\n"))
+	cmd.SilenceErrors = true
+	return statusErr
+}
+
+// columnsCompletion completes the last of the comma separated columns of tables of md.
+func columnsCompletion(md protoreflect.MessageDescriptor) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		prefix := ""
+		if i := strings.LastIndex(toComplete, ","); i >= 0 {
+			prefix = toComplete[:i+1]
+		}
+		var completions []string
+		for _, path := range output.ColumnPaths(md) {
+			completions = append(completions, prefix+path)
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
 	}
-	return nil
 }
@@ -3,22 +3,47 @@ package grpctl
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
 	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
 
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
+	"github.com/joshcarp/grpctl/internal/descriptors"
+	grpcinternal "github.com/joshcarp/grpctl/internal/grpc"
+	"github.com/joshcarp/grpctl/internal/output"
 	"github.com/joshcarp/grpctl/internal/testing/pkg/example"
 	"github.com/joshcarp/grpctl/internal/testing/proto/examplepb"
+	"github.com/joshcarp/grpctl/internal/validate"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 func TestBuildCommand(t *testing.T) {
@@ -30,6 +55,8 @@ func TestBuildCommand(t *testing.T) {
 		})
 	require.NoError(t, err)
 	addr := fmt.Sprintf("localhost:%d", port)
+	vars := filepath.Join(t.TempDir(), "vars.yaml")
+	require.NoError(t, os.WriteFile(vars, []byte("foo: Bar"), 0o600))
 	tests := []struct {
 		name    string
 		args    []string
@@ -152,6 +179,51 @@ help	Help about any command
 				"content-type:[application/grpc+proto] foo:[Bar] grpc-accept-encoding:[gzip] "+
 				"user-agent:[grpc-go-connect/1.1.0 (%s)]]\"\n}", addr, runtime.Version()),
 		},
+		{
+			name: "literal_header",
+			args: []string{
+				"grpctl",
+				"--address=http://" + addr,
+				"-H=Foo:${foo}",
+				"FooAPI",
+				"Hello",
+				"--message",
+				"{{ blah }}",
+			},
+			opts: func(args []string) []CommandOption {
+				return []CommandOption{
+					WithArgs(args),
+					WithReflection(args),
+				}
+			},
+			json: fmt.Sprintf("{\n \"message\": \"Incoming Message: {{ blah }} \\n "+
+				"Metadata: map[:authority:[%s] accept-encoding:[identity] "+
+				"content-type:[application/grpc+proto] foo:[${foo}] grpc-accept-encoding:[gzip] "+
+				"user-agent:[grpc-go-connect/1.1.0 (%s)]]\"\n}", addr, runtime.Version()),
+		},
+		{
+			name: "templated_header",
+			args: []string{
+				"grpctl",
+				"--address=http://" + addr,
+				"-H=Foo:${foo}",
+				"FooAPI",
+				"Hello",
+				"--message",
+				"blah",
+				"--vars=" + vars,
+			},
+			opts: func(args []string) []CommandOption {
+				return []CommandOption{
+					WithArgs(args),
+					WithReflection(args),
+				}
+			},
+			json: fmt.Sprintf("{\n \"message\": \"Incoming Message: blah \\n "+
+				"Metadata: map[:authority:[%s] accept-encoding:[identity] "+
+				"content-type:[application/grpc+proto] foo:[Bar] grpc-accept-encoding:[gzip] "+
+				"user-agent:[grpc-go-connect/1.1.0 (%s)]]\"\n}", addr, runtime.Version()),
+		},
 		{
 			name: "headers",
 			args: []string{
@@ -371,3 +443,3047 @@ func TestRunCommand(t *testing.T) {
 		})
 	}
 }
+
+// fileDescriptor builds a FileDescriptor from a textproto FileDescriptorProto.
+// Its options may use the extensions declared in deps, which it can import along with the global files.
+func fileDescriptor(t *testing.T, textproto string, deps ...protoreflect.FileDescriptor) protoreflect.FileDescriptor {
+	t.Helper()
+	files := protoregistry.GlobalFiles
+	types := protoregistry.GlobalTypes
+	if len(deps) > 0 {
+		files, types = new(protoregistry.Files), new(protoregistry.Types)
+		protoregistry.GlobalFiles.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			return files.RegisterFile(fd) == nil
+		})
+		for _, dep := range deps {
+			require.NoError(t, files.RegisterFile(dep))
+			for i := 0; i < dep.Extensions().Len(); i++ {
+				require.NoError(t, types.RegisterExtension(dynamicpb.NewExtensionType(dep.Extensions().Get(i))))
+			}
+		}
+	}
+	fdpb := &descriptorpb.FileDescriptorProto{}
+	require.NoError(t, prototext.UnmarshalOptions{Resolver: resolverWithGlobals{types}}.Unmarshal([]byte(textproto), fdpb))
+	fd, err := protodesc.NewFile(fdpb, files)
+	require.NoError(t, err)
+	return fd
+}
+
+// resolverWithGlobals resolves extensions from Types and then from the global types.
+type resolverWithGlobals struct {
+	*protoregistry.Types
+}
+
+func (r resolverWithGlobals) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if xt, err := r.Types.FindExtensionByName(field); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+func (r resolverWithGlobals) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	if xt, err := r.Types.FindExtensionByNumber(message, field); err == nil {
+		return xt, nil
+	}
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+const commentedProto = `
+name: "commented.proto"
+package: "commented"
+syntax: "proto3"
+message_type: {
+	name: "Request"
+	field: { name: "display_name" number: 1 type: TYPE_STRING json_name: "displayName" }
+	field: { name: "count" number: 2 type: TYPE_INT32 json_name: "count" }
+}
+service: {
+	name: "Library"
+	method: { name: "Get" input_type: ".commented.Request" output_type: ".commented.Request" }
+}
+source_code_info: {
+	location: { path: [6, 0] span: [0, 0, 0] leading_comments: " Library manages books.\n Second line.\n" }
+	location: { path: [6, 0, 2, 0] span: [0, 0, 0] leading_comments: " Get returns a book.\n" trailing_comments: " Errors if missing.\n" }
+	location: { path: [4, 0, 2, 0] span: [0, 0, 0] leading_comments: " The name\n shown to users.\n" }
+}
+`
+
+func TestCommandComments(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "service_summary",
+			args: []string{"__complete", ""},
+			want: []string{"Library\tLibrary manages books."},
+		},
+		{
+			name: "method_summary",
+			args: []string{"__complete", "Library", ""},
+			want: []string{"Get\tGet returns a book."},
+		},
+		{
+			name: "method_long",
+			args: []string{"Library", "Get", "--help"},
+			want: []string{
+				"Get returns a book.\n\nErrors if missing.\n\nGet (Unary) as defined in commented.proto",
+				"--displayName string   The name shown to users.",
+			},
+		},
+		{
+			name: "flag_without_comment",
+			args: []string{"__complete", "Library", "Get", "--c"},
+			want: []string{"--count\n"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root"}
+			var b bytes.Buffer
+			cmd.SetOut(&b)
+			cmd.SetArgs(tt.args)
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, commentedProto))))
+			require.NoError(t, cmd.ExecuteContext(context.Background()))
+			for _, want := range tt.want {
+				require.Contains(t, b.String(), want)
+			}
+		})
+	}
+}
+
+const deprecatedProto = `
+name: "deprecated.proto"
+package: "deprecated"
+syntax: "proto3"
+message_type: {
+	name: "Request"
+	field: { name: "name" number: 1 type: TYPE_STRING json_name: "name" }
+	field: { name: "old_name" number: 2 type: TYPE_STRING json_name: "oldName" options: { deprecated: true } }
+}
+service: {
+	name: "Library"
+	method: { name: "Get" input_type: ".deprecated.Request" output_type: ".deprecated.Request" }
+	method: { name: "OldGet" input_type: ".deprecated.Request" output_type: ".deprecated.Request" options: { deprecated: true } }
+}
+service: {
+	name: "OldLibrary"
+	options: { deprecated: true }
+	method: { name: "Get" input_type: ".deprecated.Request" output_type: ".deprecated.Request" }
+}
+`
+
+func TestCommandDeprecated(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		args    []string
+		opts    []CommandOption
+		want    string
+		notWant string
+		wantErr string
+	}{
+		{
+			name:    "services",
+			args:    []string{"__complete", ""},
+			want:    "Library\tLibrary as defined in deprecated.proto\n",
+			notWant: "OldLibrary",
+		},
+		{
+			name:    "methods",
+			args:    []string{"__complete", "Library", ""},
+			want:    "Get\tGet (Unary) as defined in deprecated.proto\n:4\n",
+			notWant: "OldGet",
+		},
+		{
+			name:    "fields",
+			args:    []string{"__complete", "Library", "Get", "--"},
+			want:    "--name\n",
+			notWant: "oldName",
+		},
+		{
+			name:    "method_warning",
+			args:    []string{"Library", "OldGet"},
+			wantErr: `Command "OldGet" is deprecated, deprecated.Library.OldGet is marked as deprecated in deprecated.proto`,
+		},
+		{
+			name:    "service_warning",
+			args:    []string{"OldLibrary", "Get"},
+			wantErr: `Command "Get" is deprecated, deprecated.OldLibrary is marked as deprecated in deprecated.proto`,
+		},
+		{
+			name:    "field_warning",
+			args:    []string{"Library", "Get", "--oldName=foo"},
+			wantErr: "Flag --oldName has been deprecated, deprecated.Request.old_name is marked as deprecated in deprecated.proto",
+		},
+		{
+			name:    "hidden_methods",
+			args:    []string{"Library", "OldGet"},
+			opts:    []CommandOption{WithHideDeprecated()},
+			want:    "Available Commands:\n  Get ",
+			notWant: "OldGet",
+		},
+		{
+			name:    "hidden_services",
+			args:    []string{"OldLibrary", "Get"},
+			opts:    []CommandOption{WithHideDeprecated()},
+			wantErr: `unknown command "OldLibrary" for "root"`,
+		},
+		{
+			name:    "hidden_fields",
+			args:    []string{"Library", "Get", "--oldName=foo"},
+			opts:    []CommandOption{WithHideDeprecated()},
+			wantErr: "unknown flag: --oldName",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(tt.args)
+			opts := append(tt.opts, WithFileDescriptors(fileDescriptor(t, deprecatedProto)))
+			require.NoError(t, BuildCommand(cmd, opts...))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				if err != nil {
+					require.Contains(t, err.Error(), tt.wantErr)
+					return
+				}
+				require.Contains(t, stdout.String()+stderr.String(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, stdout.String(), tt.want)
+			require.NotContains(t, stdout.String(), tt.notWant)
+		})
+	}
+}
+
+func packagedProto(path, pkg, service string) string {
+	return fmt.Sprintf(`
+name: %[1]q
+package: %[2]q
+syntax: "proto3"
+message_type: { name: "Request" field: { name: "name" number: 1 type: TYPE_STRING json_name: "name" } }
+service: { name: %[3]q method: { name: "Get" input_type: ".%[2]s.Request" output_type: ".%[2]s.Request" } }
+`, path, pkg, service)
+}
+
+func TestPackageNaming(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		naming   PackageNaming
+		strategy NamingStrategy
+		args     []string
+		want     []string
+		buildErr string
+		wantErr  string
+	}{
+		{
+			name:     "short_collision",
+			naming:   ShortNames,
+			buildErr: "duplicate service name: UserService in foo/v2/api.proto",
+		},
+		{
+			name:   "qualified_root",
+			naming: QualifiedNames,
+			args:   []string{"__complete", ""},
+			want:   []string{"bar\tServices in the bar package\n", "foo\tServices in the foo package\n"},
+		},
+		{
+			name:   "qualified_package",
+			naming: QualifiedNames,
+			args:   []string{"__complete", "foo", "v2", ""},
+			want:   []string{"UserService\tUserService as defined in foo/v2/api.proto\n"},
+		},
+		{
+			name:   "qualified_method",
+			naming: QualifiedNames,
+			args:   []string{"__complete", "foo", "v1", "UserService", ""},
+			want:   []string{"Get\tGet (Unary) as defined in foo/v1/api.proto\n"},
+		},
+		{
+			name:   "qualified_short_form",
+			naming: QualifiedNames,
+			args:   []string{"OtherService", "Get", "--name=foo", "--show-request"},
+			want:   []string{`"name": "foo"`},
+		},
+		{
+			name:    "qualified_ambiguous_short_form",
+			naming:  QualifiedNames,
+			args:    []string{"UserService", "Get", "--name=foo"},
+			wantErr: "UserService is ambiguous, use one of: foo v1 UserService, foo v2 UserService",
+		},
+		{
+			name:   "disambiguated_root",
+			naming: DisambiguatedNames,
+			args:   []string{"__complete", ""},
+			want: []string{
+				"OtherService\tOtherService as defined in bar/api.proto\n",
+				"foo.v1.UserService\tUserService as defined in foo/v1/api.proto\n",
+				"foo.v2.UserService\tUserService as defined in foo/v2/api.proto\n",
+			},
+		},
+		{
+			name:   "disambiguated_suffix_alias",
+			naming: DisambiguatedNames,
+			args:   []string{"__complete", "v2.UserService", ""},
+			want:   []string{"Get\tGet (Unary) as defined in foo/v2/api.proto\n"},
+		},
+		{
+			name:     "disambiguated_kebab_case",
+			naming:   DisambiguatedNames,
+			strategy: KebabCaseNaming(),
+			args:     []string{"__complete", ""},
+			want: []string{
+				"other-service\tOtherService as defined in bar/api.proto\n",
+				"foo.v1.user-service\tUserService as defined in foo/v1/api.proto\n",
+				"foo.v2.user-service\tUserService as defined in foo/v2/api.proto\n",
+			},
+		},
+		{
+			name:     "disambiguated_kebab_case_suffix_alias",
+			naming:   DisambiguatedNames,
+			strategy: KebabCaseNaming(),
+			args:     []string{"v1.user-service", "get", "--name=foo", "--show-request"},
+			want:     []string{`"name": "foo"`},
+		},
+		{
+			name:     "disambiguated_kebab_case_proto_alias",
+			naming:   DisambiguatedNames,
+			strategy: KebabCaseNaming(),
+			args:     []string{"foo.v2.UserService", "Get", "--name=foo", "--show-request"},
+			want:     []string{`"name": "foo"`},
+		},
+		{
+			name:   "disambiguated_qualified_alias",
+			naming: DisambiguatedNames,
+			args:   []string{"__complete", "bar.OtherService", ""},
+			want:   []string{"Get\tGet (Unary) as defined in bar/api.proto\n"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root"}
+			var b bytes.Buffer
+			cmd.SetOut(&b)
+			cmd.SetErr(&b)
+			cmd.SetArgs(tt.args)
+			err := BuildCommand(cmd,
+				WithPackageNaming(tt.naming),
+				WithNamingStrategy(tt.strategy),
+				WithFileDescriptors(
+					fileDescriptor(t, packagedProto("foo/v1/api.proto", "foo.v1", "UserService")),
+					fileDescriptor(t, packagedProto("bar/api.proto", "bar", "OtherService")),
+					fileDescriptor(t, packagedProto("foo/v2/api.proto", "foo.v2", "UserService")),
+				),
+			)
+			if tt.buildErr != "" {
+				require.EqualError(t, err, tt.buildErr)
+				return
+			}
+			require.NoError(t, err)
+			err = cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for _, want := range tt.want {
+				require.Contains(t, b.String(), want)
+			}
+		})
+	}
+}
+
+func TestNamingStrategy(t *testing.T) {
+	t.Parallel()
+	custom := NamingStrategy{
+		Command: func(descriptor protoreflect.Descriptor) string {
+			return strings.ToLower(string(descriptor.Name()))
+		},
+		Flag: func(field protoreflect.FieldDescriptor) string {
+			return "set-" + string(field.Name())
+		},
+	}
+	tests := []struct {
+		name    string
+		naming  NamingStrategy
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "kebab_commands",
+			naming: KebabCaseNaming(),
+			args:   []string{"__complete", ""},
+			want:   "library\tLibrary manages books.\n",
+		},
+		{
+			name:   "kebab_flags",
+			naming: KebabCaseNaming(),
+			args:   []string{"__complete", "library", "get", "--d"},
+			want:   "--display-name\tThe name shown to users.\n",
+		},
+		{
+			name:   "kebab_accepts_original_names",
+			naming: KebabCaseNaming(),
+			args:   []string{"Library", "Get", "--displayName=foo", "--display_name=foo"},
+		},
+		{
+			name:   "proto_flags",
+			naming: ProtoNaming(),
+			args:   []string{"__complete", "Library", "Get", "--d"},
+			want:   "--display_name\tThe name shown to users.\n",
+		},
+		{
+			name:   "custom",
+			naming: custom,
+			args:   []string{"__complete", "library", "get", "--set-d"},
+			want:   "--set-display_name\tThe name shown to users.\n",
+		},
+		{
+			name:    "unknown_flag",
+			naming:  KebabCaseNaming(),
+			args:    []string{"library", "get", "--display-names=foo"},
+			wantErr: "unknown flag: --display-names",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var b bytes.Buffer
+			cmd.SetOut(&b)
+			cmd.SetArgs(tt.args)
+			require.NoError(t, BuildCommand(cmd, WithNamingStrategy(tt.naming), WithFileDescriptors(fileDescriptor(t, commentedProto))))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, b.String(), tt.want)
+		})
+	}
+}
+
+func TestKebabCase(t *testing.T) {
+	t.Parallel()
+	for in, want := range map[string]string{
+		"ListBillingAccounts": "list-billing-accounts",
+		"display_name":        "display-name",
+		"displayName":         "display-name",
+		"GetHTTPServer":       "get-http-server",
+		"ServerV2":            "server-v2",
+		"name":                "name",
+	} {
+		require.Equal(t, want, kebabCase(in), in)
+	}
+}
+
+const nestedProto = `
+name: "nested.proto"
+package: "nested"
+syntax: "proto3"
+message_type: {
+	name: "Request"
+	field: { name: "name" number: 1 type: TYPE_STRING json_name: "name" }
+	field: { name: "billing_account" number: 2 type: TYPE_MESSAGE type_name: ".nested.Account" json_name: "billingAccount" }
+	field: { name: "tags" number: 3 label: LABEL_REPEATED type: TYPE_STRING json_name: "tags" }
+	field: { name: "counts" number: 4 label: LABEL_REPEATED type: TYPE_UINT32 json_name: "counts" }
+	field: { name: "accounts" number: 5 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".nested.Account" json_name: "accounts" }
+	field: { name: "labels" number: 6 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".nested.Request.LabelsEntry" json_name: "labels" }
+	field: { name: "limits" number: 7 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".nested.Request.LimitsEntry" json_name: "limits" }
+	field: { name: "owners" number: 8 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".nested.Request.OwnersEntry" json_name: "owners" }
+	field: { name: "state" number: 9 type: TYPE_ENUM type_name: ".nested.BillingState" json_name: "state" }
+	field: { name: "states" number: 10 label: LABEL_REPEATED type: TYPE_ENUM type_name: ".nested.BillingState" json_name: "states" }
+	nested_type: {
+		name: "LabelsEntry"
+		field: { name: "key" number: 1 type: TYPE_STRING json_name: "key" }
+		field: { name: "value" number: 2 type: TYPE_STRING json_name: "value" }
+		options: { map_entry: true }
+	}
+	nested_type: {
+		name: "LimitsEntry"
+		field: { name: "key" number: 1 type: TYPE_INT32 json_name: "key" }
+		field: { name: "value" number: 2 type: TYPE_DOUBLE json_name: "value" }
+		options: { map_entry: true }
+	}
+	nested_type: {
+		name: "OwnersEntry"
+		field: { name: "key" number: 1 type: TYPE_STRING json_name: "key" }
+		field: { name: "value" number: 2 type: TYPE_MESSAGE type_name: ".nested.Account" json_name: "value" }
+		options: { map_entry: true }
+	}
+}
+message_type: {
+	name: "Account"
+	field: { name: "display_name" number: 1 type: TYPE_STRING json_name: "displayName" }
+	field: { name: "count" number: 2 type: TYPE_INT32 json_name: "count" }
+	field: { name: "parent" number: 3 type: TYPE_MESSAGE type_name: ".nested.Account" json_name: "parent" }
+}
+enum_type: {
+	name: "BillingState"
+	value: { name: "BILLING_STATE_UNSPECIFIED" number: 0 }
+	value: { name: "BILLING_STATE_OPEN" number: 1 }
+	value: { name: "BILLING_STATE_CLOSED" number: 2 }
+}
+service: {
+	name: "Library"
+	method: { name: "Get" input_type: ".nested.Request" output_type: ".nested.Request" }
+}
+source_code_info: {
+	location: { path: [5, 0, 2, 1] span: [0, 0, 0] leading_comments: " The account can be billed.\n" }
+}
+`
+
+// requestJSON parses args with the flags generated for the input of the first method in fd and returns the request JSON.
+func requestJSON(t *testing.T, fd protoreflect.FileDescriptor, naming NamingStrategy, args ...string) (string, error) {
+	t.Helper()
+	builder := newFlagBuilder(&settings{naming: naming})
+	input := fd.Services().Get(0).Methods().Get(0).Input()
+	builder.addMessage(input, "", nil, []protoreflect.MessageDescriptor{input}, true)
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	for name, val := range builder.dataMap {
+		val.Stdin = func() io.Reader { return strings.NewReader("stdin") }
+		flags.Var(val, name, val.Usage)
+	}
+	flags.SetNormalizeFunc(normalizeFlags(builder.aliases))
+	if err := flags.Parse(args); err != nil {
+		return "", err
+	}
+	b, err := builder.dataMap.ToJSON()
+	return string(b), err
+}
+
+func TestNestedFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		naming  NamingStrategy
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "unset",
+			want: `{}`,
+		},
+		{
+			name: "partial",
+			args: []string{"--billingAccount.displayName=foo"},
+			want: `{"billingAccount": {"displayName": "foo"}}`,
+		},
+		{
+			name: "merged",
+			args: []string{"--name=a", "--billingAccount.displayName=foo", "--billingAccount.count=2"},
+			want: `{"name": "a", "billingAccount": {"displayName": "foo", "count": 2}}`,
+		},
+		{
+			name: "recursive_message_as_json",
+			args: []string{"--billingAccount.parent", `{"displayName": "bar"}`},
+			want: `{"billingAccount": {"parent": {"displayName": "bar"}}}`,
+		},
+		{
+			name:    "typed",
+			args:    []string{"--billingAccount.count=foo"},
+			wantErr: `invalid argument "foo" for "--billingAccount.count" flag: strconv.ParseInt: parsing "foo": invalid syntax`,
+		},
+		{
+			name:   "aliases",
+			naming: KebabCaseNaming(),
+			args:   []string{"--billing_account.displayName=foo", "--billingAccount.count=2"},
+			want:   `{"billingAccount": {"displayName": "foo", "count": 2}}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := requestJSON(t, fileDescriptor(t, nestedProto), tt.naming, tt.args...)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, got)
+		})
+	}
+}
+
+func TestRepeatedFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "repeated",
+			args: []string{"--tags=a", "--tags=b"},
+			want: `{"tags": ["a", "b"]}`,
+		},
+		{
+			name: "comma_separated",
+			args: []string{"--tags=a,b", "--tags=c", "--counts=1,2"},
+			want: `{"tags": ["a", "b", "c"], "counts": [1, 2]}`,
+		},
+		{
+			name: "quoted_commas",
+			args: []string{`--tags="a,b",c`},
+			want: `{"tags": ["a,b", "c"]}`,
+		},
+		{
+			name: "empty",
+			args: []string{"--tags=", "--tags=a"},
+			want: `{"tags": ["", "a"]}`,
+		},
+		{
+			name: "stray_quote",
+			args: []string{`--tags=a"b`, `--tags=c"`},
+			want: `{"tags": ["a\"b", "c\""]}`,
+		},
+		{
+			name: "quoted_newline",
+			args: []string{"--tags=\"a\nb\",c"},
+			want: `{"tags": ["a\nb", "c"]}`,
+		},
+		{
+			name:    "newline",
+			args:    []string{"--tags=a\nb"},
+			wantErr: `invalid argument "a\nb" for "--tags" flag: invalid comma separated list, quote elements that contain commas or newlines as in "a,b",c: it has more than one line`,
+		},
+		{
+			name:    "element_type",
+			args:    []string{"--counts=1,-2"},
+			wantErr: `invalid argument "1,-2" for "--counts" flag: strconv.ParseUint: parsing "-2": invalid syntax`,
+		},
+		{
+			name: "messages",
+			args: []string{"--accounts", `{"displayName": "a", "count": 1}`, "--accounts", `{"displayName": "b"}`},
+			want: `{"accounts": [{"displayName": "a", "count": 1}, {"displayName": "b"}]}`,
+		},
+		{
+			name:    "invalid_message",
+			args:    []string{"--accounts=a"},
+			wantErr: `invalid argument "a" for "--accounts" flag: "a" is not a JSON object`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := requestJSON(t, fileDescriptor(t, nestedProto), DefaultNaming(), tt.args...)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, got)
+		})
+	}
+}
+
+func TestRepeatedFlagCompletion(t *testing.T) {
+	t.Parallel()
+	cmd := &cobra.Command{Use: "root"}
+	var b bytes.Buffer
+	cmd.SetOut(&b)
+	cmd.SetArgs([]string{"__complete", "Library", "Get", "--tags=a", "--name=b", "--"})
+	require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, nestedProto))))
+	require.NoError(t, cmd.ExecuteContext(context.Background()))
+	require.Contains(t, b.String(), "--tags\t(repeatable)\n")
+	require.NotContains(t, b.String(), "--name")
+}
+
+func TestMapFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "strings",
+			args: []string{"--labels", "env=prod", "--labels", "team=a=b"},
+			want: `{"labels": {"env": "prod", "team": "a=b"}}`,
+		},
+		{
+			name: "typed",
+			args: []string{"--limits", "1=2.5", "--limits", "-3=4"},
+			want: `{"limits": {"1": 2.5, "-3": 4}}`,
+		},
+		{
+			name: "messages",
+			args: []string{"--owners", `a={"displayName": "b"}`},
+			want: `{"owners": {"a": {"displayName": "b"}}}`,
+		},
+		{
+			name:    "missing_value",
+			args:    []string{"--labels", "env"},
+			wantErr: `invalid argument "env" for "--labels" flag: "env" is not in the form key=value`,
+		},
+		{
+			name:    "invalid_key",
+			args:    []string{"--limits", "a=1"},
+			wantErr: `invalid argument "a=1" for "--limits" flag: invalid int32 key "a": strconv.ParseInt: parsing "a": invalid syntax`,
+		},
+		{
+			name:    "invalid_value",
+			args:    []string{"--limits", "1=a"},
+			wantErr: `invalid argument "1=a" for "--limits" flag: invalid double value for key "1": strconv.ParseFloat: parsing "a": invalid syntax`,
+		},
+		{
+			name:    "invalid_message",
+			args:    []string{"--owners", "a=b"},
+			wantErr: `invalid argument "a=b" for "--owners" flag: invalid message value for key "a": "b" is not a JSON object`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := requestJSON(t, fileDescriptor(t, nestedProto), DefaultNaming(), tt.args...)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, got)
+		})
+	}
+}
+
+func TestEnumFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "name",
+			args: []string{"--state=BILLING_STATE_OPEN"},
+			want: `{"state": "BILLING_STATE_OPEN"}`,
+		},
+		{
+			name: "case_insensitive_without_prefix",
+			args: []string{"--state=closed"},
+			want: `{"state": "BILLING_STATE_CLOSED"}`,
+		},
+		{
+			name: "number",
+			args: []string{"--state=2"},
+			want: `{"state": 2}`,
+		},
+		{
+			name: "repeated",
+			args: []string{"--states=open,billing_state_closed"},
+			want: `{"states": ["BILLING_STATE_OPEN", "BILLING_STATE_CLOSED"]}`,
+		},
+		{
+			name: "invalid",
+			args: []string{"--state=pending"},
+			wantErr: `invalid argument "pending" for "--state" flag: invalid value "pending" for enum nested.BillingState, ` +
+				`expected one of: BILLING_STATE_UNSPECIFIED, BILLING_STATE_OPEN, BILLING_STATE_CLOSED`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := requestJSON(t, fileDescriptor(t, nestedProto), DefaultNaming(), tt.args...)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, got)
+		})
+	}
+}
+
+func TestEnumFlagCompletion(t *testing.T) {
+	t.Parallel()
+	cmd := &cobra.Command{Use: "root"}
+	var b bytes.Buffer
+	cmd.SetOut(&b)
+	cmd.SetArgs([]string{"__complete", "Library", "Get", "--state", ""})
+	require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, nestedProto))))
+	require.NoError(t, cmd.ExecuteContext(context.Background()))
+	require.Equal(t, "BILLING_STATE_UNSPECIFIED\nBILLING_STATE_OPEN\tThe account can be billed.\nBILLING_STATE_CLOSED\n:4\n", b.String())
+}
+
+const wellKnownProto = `
+name: "wellknown.proto"
+package: "wellknown"
+syntax: "proto3"
+dependency: ["google/protobuf/timestamp.proto", "google/protobuf/duration.proto", "google/protobuf/field_mask.proto",
+	"google/protobuf/wrappers.proto", "google/protobuf/struct.proto", "google/protobuf/any.proto"]
+message_type: {
+	name: "Request"
+	field: { name: "create_time" number: 1 type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" json_name: "createTime" }
+	field: { name: "ttl" number: 2 type: TYPE_MESSAGE type_name: ".google.protobuf.Duration" json_name: "ttl" }
+	field: { name: "update_mask" number: 3 type: TYPE_MESSAGE type_name: ".google.protobuf.FieldMask" json_name: "updateMask" }
+	field: { name: "limit" number: 4 type: TYPE_MESSAGE type_name: ".google.protobuf.Int32Value" json_name: "limit" }
+	field: { name: "enabled" number: 5 type: TYPE_MESSAGE type_name: ".google.protobuf.BoolValue" json_name: "enabled" }
+	field: { name: "metadata" number: 6 type: TYPE_MESSAGE type_name: ".google.protobuf.Struct" json_name: "metadata" }
+	field: { name: "value" number: 7 type: TYPE_MESSAGE type_name: ".google.protobuf.Value" json_name: "value" }
+	field: { name: "detail" number: 8 type: TYPE_MESSAGE type_name: ".google.protobuf.Any" json_name: "detail" }
+	field: { name: "payload" number: 9 type: TYPE_BYTES json_name: "payload" }
+	field: { name: "blob" number: 10 type: TYPE_MESSAGE type_name: ".google.protobuf.BytesValue" json_name: "blob" }
+	field: { name: "chunks" number: 11 label: LABEL_REPEATED type: TYPE_BYTES json_name: "chunks" }
+	field: { name: "parent" number: 12 type: TYPE_MESSAGE type_name: ".wellknown.Request" json_name: "parent" }
+}
+service: {
+	name: "Library"
+	method: { name: "Get" input_type: ".wellknown.Request" output_type: ".wellknown.Request" }
+}
+`
+
+func TestWellKnownFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "timestamp",
+			args: []string{"--createTime=2020-01-02T03:04:05+01:00"},
+			want: `{"createTime": "2020-01-02T02:04:05Z"}`,
+		},
+		{
+			name: "date",
+			args: []string{"--createTime=2020-01-02"},
+			want: `{"createTime": "2020-01-02T00:00:00Z"}`,
+		},
+		{
+			name: "duration",
+			args: []string{"--ttl=1h30m"},
+			want: `{"ttl": "5400s"}`,
+		},
+		{
+			name: "field_mask",
+			args: []string{"--updateMask=display_name,billingAccount.open"},
+			want: `{"updateMask": "displayName,billingAccount.open"}`,
+		},
+		{
+			name: "wrappers",
+			args: []string{"--limit=5", "--enabled=true"},
+			want: `{"limit": 5, "enabled": true}`,
+		},
+		{
+			name: "struct_and_value",
+			args: []string{`--metadata={"a": [1, 2]}`, "--value=foo"},
+			want: `{"metadata": {"a": [1, 2]}, "value": "foo"}`,
+		},
+		{
+			name: "any",
+			args: []string{`--detail={"@type": "type.googleapis.com/google.protobuf.Duration", "value": "1s"}`},
+			want: `{"detail": {"@type": "type.googleapis.com/google.protobuf.Duration", "value": "1s"}}`,
+		},
+		{
+			name:    "invalid_duration",
+			args:    []string{"--ttl=1 hour"},
+			wantErr: `invalid argument "1 hour" for "--ttl" flag: time: unknown unit " hour" in duration "1 hour"`,
+		},
+		{
+			name:    "any_without_type",
+			args:    []string{`--detail={"value": "1s"}`},
+			wantErr: `invalid argument "{\"value\": \"1s\"}" for "--detail" flag: "{\"value\": \"1s\"}" has no @type`,
+		},
+		{
+			name:    "invalid_wrapper",
+			args:    []string{"--limit=five"},
+			wantErr: `invalid argument "five" for "--limit" flag: strconv.ParseInt: parsing "five": invalid syntax`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fd := fileDescriptor(t, wellKnownProto)
+			got, err := requestJSON(t, fd, DefaultNaming(), tt.args...)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, got)
+			_, err = grpcinternal.ParseMessage([]byte(got), fd.Messages().Get(0), protojson.UnmarshalOptions{}, true)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for in, want := range map[string]time.Time{
+		"now":                  now,
+		"now-1h":               now.Add(-time.Hour),
+		"now+90s":              now.Add(90 * time.Second),
+		"2021-01-01T00:00:00Z": time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		got, err := descriptors.ParseTimestamp(in, now)
+		require.NoError(t, err, in)
+		require.True(t, want.Equal(got), in)
+	}
+	_, err := descriptors.ParseTimestamp("now1h", now)
+	require.EqualError(t, err, `invalid relative timestamp "now1h", expected eg now-1h`)
+}
+
+func TestBytesFlags(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "payload.bin")
+	require.NoError(t, os.WriteFile(file, []byte{0, 1, 2}, 0o600))
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "base64",
+			args: []string{"--payload=aGVsbG8=", "--blob=aGVsbG8"},
+			want: `{"payload": "aGVsbG8=", "blob": "aGVsbG8="}`,
+		},
+		{
+			name: "raw",
+			args: []string{"--payload=raw:hello"},
+			want: `{"payload": "aGVsbG8="}`,
+		},
+		{
+			name:    "invalid_base64",
+			args:    []string{"--payload=hello"},
+			wantErr: `invalid argument "hello" for "--payload" flag: illegal base64 data at input byte 4, use raw:hello to send the text as it is`,
+		},
+		{
+			name: "file",
+			args: []string{"--payload=@" + file},
+			want: `{"payload": "AAEC"}`,
+		},
+		{
+			name: "stdin",
+			args: []string{"--payload=-"},
+			want: `{"payload": "c3RkaW4="}`,
+		},
+		{
+			name: "encoded",
+			args: []string{"--payload=base64:AAEC", "--blob=hex:000102", "--chunks=base64:AAE,hex:ff"},
+			want: `{"payload": "AAEC", "blob": "AAEC", "chunks": ["AAE=", "/w=="]}`,
+		},
+		{
+			name:    "invalid_hex",
+			args:    []string{"--payload=hex:zz"},
+			wantErr: `invalid argument "hex:zz" for "--payload" flag: encoding/hex: invalid byte: U+007A 'z'`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := requestJSON(t, fileDescriptor(t, wellKnownProto), DefaultNaming(), tt.args...)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, got)
+		})
+	}
+}
+
+func TestExpandBytesFiles(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "payload.bin")
+	require.NoError(t, os.WriteFile(file, []byte{0, 1, 2}, 0o600))
+	md := fileDescriptor(t, wellKnownProto).Messages().Get(0)
+	in := fmt.Sprintf(`{"payload": "@%[1]s", "chunks": ["@%[1]s", "AAE="], "parent": {"blob": "@%[1]s"}, "value": "@%[1]s"}`, file)
+	msg, err := descriptors.UnmarshalJSON([]byte(in))
+	require.NoError(t, err)
+	require.NoError(t, descriptors.ExpandMessageBytesFiles(md, msg))
+	got, err := json.Marshal(msg)
+	require.NoError(t, err)
+	want := fmt.Sprintf(`{"payload": "AAEC", "chunks": ["AAEC", "AAE="], "parent": {"blob": "AAEC"}, "value": "@%s"}`, file)
+	require.JSONEq(t, want, string(got))
+
+	err = descriptors.ExpandMessageBytesFiles(md, map[string]interface{}{"payload": "@missing.bin"})
+	require.EqualError(t, err, "wellknown.Request.payload: open missing.bin: no such file or directory")
+}
+
+const behaviorProto = `
+name: "behavior.proto"
+package: "behavior"
+syntax: "proto3"
+message_type: {
+	name: "Request"
+	field: { name: "name" number: 1 type: TYPE_STRING json_name: "name" options: { [google.api.field_behavior]: REQUIRED } }
+	field: { name: "create_time" number: 2 type: TYPE_STRING json_name: "createTime" options: { [google.api.field_behavior]: OUTPUT_ONLY } }
+	field: { name: "billing_account" number: 3 type: TYPE_MESSAGE type_name: ".behavior.Account" json_name: "billingAccount" }
+	field: { name: "email" number: 4 type: TYPE_STRING json_name: "email" oneof_index: 0 }
+	field: { name: "phone" number: 5 type: TYPE_STRING json_name: "phone" oneof_index: 0 }
+	field: { name: "address" number: 6 type: TYPE_MESSAGE type_name: ".behavior.Address" json_name: "address" oneof_index: 0 }
+	oneof_decl: { name: "contact" }
+}
+message_type: {
+	name: "Account"
+	field: { name: "account_id" number: 1 type: TYPE_STRING json_name: "accountId" options: { [google.api.field_behavior]: REQUIRED } }
+}
+message_type: {
+	name: "Address"
+	field: { name: "city" number: 1 type: TYPE_STRING json_name: "city" }
+}
+service: {
+	name: "Library"
+	method: { name: "Get" input_type: ".behavior.Request" output_type: ".behavior.Request" }
+	method: { name: "Upload" input_type: ".behavior.Request" output_type: ".behavior.Request" client_streaming: true }
+}
+`
+
+func TestFieldBehaviorFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		method  string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "missing_required",
+			args:    []string{"--email=a"},
+			wantErr: `required flag(s) "name" not set`,
+		},
+		{
+			name: "required_flag",
+			args: []string{"--name=a"},
+		},
+		{
+			name: "required_json_data",
+			args: []string{`--json-data={"name": "a"}`},
+		},
+		{
+			name:    "required_in_set_message",
+			args:    []string{`--json-data={"name": "a", "billing_account": {}}`},
+			wantErr: `required flag(s) "billingAccount.accountId" not set`,
+		},
+		{
+			name: "required_in_message",
+			args: []string{"--name=a", "--billingAccount.accountId=b"},
+		},
+		{
+			name:    "oneof",
+			args:    []string{"--name=a", "--email=b", "--phone=c"},
+			wantErr: "if any flags in the group [email phone] are set none of the others can be; [email phone] were all set",
+		},
+		{
+			name:    "oneof_message",
+			args:    []string{"--name=a", "--phone=b", "--address.city=c"},
+			wantErr: "if any flags in the group [phone address.city] are set none of the others can be; [address.city phone] were all set",
+		},
+		{
+			// The required fields of streamed requests are checked before the call, so the address isn't dialed.
+			name:    "streaming_missing_required",
+			method:  "Upload",
+			args:    []string{"--address=http://localhost:1", `--json-data=[{"name": "a"}, {"email": "b"}]`},
+			wantErr: `request 2: required flag(s) "name" not set`,
+		},
+		{
+			name:    "output_only",
+			args:    []string{"--name=a", "--createTime=b"},
+			wantErr: "unknown flag: --createTime",
+		},
+		{
+			name: "usage",
+			args: []string{"--help"},
+			want: "--name string                       (required)",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stdout bytes.Buffer
+			cmd.SetOut(&stdout)
+			method := "Get"
+			if tt.method != "" {
+				method = tt.method
+			}
+			cmd.SetArgs(append([]string{"Library", method}, tt.args...))
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, behaviorProto))))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, stdout.String(), tt.want)
+		})
+	}
+}
+
+// bufValidateProto is the subset of buf/validate/validate.proto that the tests use.
+const bufValidateProto = `
+name: "buf/validate/validate.proto"
+package: "buf.validate"
+dependency: "google/protobuf/descriptor.proto"
+message_type: {
+	name: "MessageConstraints"
+	field: { name: "disabled" number: 1 label: LABEL_OPTIONAL type: TYPE_BOOL }
+	field: { name: "cel" number: 3 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".buf.validate.Constraint" }
+}
+message_type: {
+	name: "OneofConstraints"
+	field: { name: "required" number: 1 label: LABEL_OPTIONAL type: TYPE_BOOL }
+}
+message_type: {
+	name: "Constraint"
+	field: { name: "id" number: 1 label: LABEL_OPTIONAL type: TYPE_STRING }
+	field: { name: "message" number: 2 label: LABEL_OPTIONAL type: TYPE_STRING }
+	field: { name: "expression" number: 3 label: LABEL_OPTIONAL type: TYPE_STRING }
+}
+message_type: {
+	name: "FieldConstraints"
+	field: { name: "cel" number: 23 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".buf.validate.Constraint" }
+	field: { name: "required" number: 25 label: LABEL_OPTIONAL type: TYPE_BOOL }
+	field: { name: "int32" number: 3 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".buf.validate.Int32Rules" oneof_index: 0 }
+	field: { name: "string" number: 14 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".buf.validate.StringRules" oneof_index: 0 }
+	field: { name: "repeated" number: 18 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".buf.validate.RepeatedRules" oneof_index: 0 }
+	oneof_decl: { name: "type" }
+}
+message_type: {
+	name: "Int32Rules"
+	field: { name: "lt" number: 2 label: LABEL_OPTIONAL type: TYPE_INT32 }
+	field: { name: "gte" number: 5 label: LABEL_OPTIONAL type: TYPE_INT32 }
+	field: { name: "in" number: 6 label: LABEL_REPEATED type: TYPE_INT32 }
+}
+message_type: {
+	name: "StringRules"
+	field: { name: "min_len" number: 2 label: LABEL_OPTIONAL type: TYPE_UINT64 }
+	field: { name: "max_len" number: 3 label: LABEL_OPTIONAL type: TYPE_UINT64 }
+	field: { name: "pattern" number: 6 label: LABEL_OPTIONAL type: TYPE_STRING }
+}
+message_type: {
+	name: "RepeatedRules"
+	field: { name: "min_items" number: 1 label: LABEL_OPTIONAL type: TYPE_UINT64 }
+	field: { name: "unique" number: 3 label: LABEL_OPTIONAL type: TYPE_BOOL }
+	field: { name: "items" number: 4 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".buf.validate.FieldConstraints" }
+}
+extension: { name: "message" number: 1159 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".buf.validate.MessageConstraints" extendee: ".google.protobuf.MessageOptions" }
+extension: { name: "oneof" number: 1159 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".buf.validate.OneofConstraints" extendee: ".google.protobuf.OneofOptions" }
+extension: { name: "field" number: 1159 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".buf.validate.FieldConstraints" extendee: ".google.protobuf.FieldOptions" }
+`
+
+// legacyValidateProto is the subset of validate/validate.proto of protoc-gen-validate that the tests use.
+const legacyValidateProto = `
+name: "validate/validate.proto"
+package: "validate"
+dependency: "google/protobuf/descriptor.proto"
+message_type: {
+	name: "FieldRules"
+	field: { name: "message" number: 17 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".validate.MessageRules" }
+	field: { name: "uint32" number: 5 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".validate.UInt32Rules" oneof_index: 0 }
+	oneof_decl: { name: "type" }
+}
+message_type: {
+	name: "MessageRules"
+	field: { name: "required" number: 2 label: LABEL_OPTIONAL type: TYPE_BOOL }
+}
+message_type: {
+	name: "UInt32Rules"
+	field: { name: "gt" number: 4 label: LABEL_OPTIONAL type: TYPE_UINT32 }
+	field: { name: "lte" number: 3 label: LABEL_OPTIONAL type: TYPE_UINT32 }
+}
+extension: { name: "rules" number: 1071 label: LABEL_OPTIONAL type: TYPE_MESSAGE type_name: ".validate.FieldRules" extendee: ".google.protobuf.FieldOptions" }
+`
+
+const validatedProto = `
+name: "validated.proto"
+package: "validated"
+syntax: "proto3"
+dependency: "buf/validate/validate.proto"
+dependency: "validate/validate.proto"
+message_type: {
+	name: "Request"
+	field: { name: "name" number: 1 type: TYPE_STRING json_name: "name" options: { [buf.validate.field]: { string: { min_len: 3 max_len: 8 pattern: "^[a-z]+$" } } } }
+	field: { name: "count" number: 2 type: TYPE_INT32 json_name: "count" options: { [buf.validate.field]: { int32: { gte: 1 lt: 10 } } } }
+	field: {
+		name: "tags" number: 3 label: LABEL_REPEATED type: TYPE_STRING json_name: "tags"
+		options: { [buf.validate.field]: { repeated: { min_items: 1 unique: true items: { string: { min_len: 2 } } } } }
+	}
+	field: { name: "account" number: 4 type: TYPE_MESSAGE type_name: ".validated.Account" json_name: "account" options: { [validate.rules]: { message: { required: true } } } }
+	field: { name: "email" number: 5 type: TYPE_STRING json_name: "email" oneof_index: 0 }
+	field: { name: "phone" number: 6 type: TYPE_STRING json_name: "phone" oneof_index: 0 }
+	oneof_decl: { name: "contact" options: { [buf.validate.oneof]: { required: true } } }
+	options: { [buf.validate.message]: { cel: { id: "name_not_email" message: "name must not equal email" expression: "this.name != this.email" } } }
+}
+message_type: {
+	name: "Account"
+	field: { name: "id" number: 1 type: TYPE_UINT32 json_name: "id" options: { [validate.rules]: { uint32: { gt: 0 lte: 100 } } } }
+	field: {
+		name: "code" number: 2 type: TYPE_STRING json_name: "code"
+		options: { [buf.validate.field]: {
+			cel: { id: "code.upper" expression: "this == this.upperAscii() ? '' : 'code must be upper case'" }
+			cel: { id: "code.prefix" message: "code must start with X" expression: "this.startsWith('X')" }
+			cel: { expression: "this != 'xy'" }
+		} }
+	}
+}
+service: {
+	name: "Library"
+	method: { name: "Get" input_type: ".validated.Request" output_type: ".validated.Request" }
+}
+`
+
+func TestValidateRequest(t *testing.T) {
+	t.Parallel()
+	bufValidate := fileDescriptor(t, bufValidateProto)
+	legacyValidate := fileDescriptor(t, legacyValidateProto)
+	input := fileDescriptor(t, validatedProto, bufValidate, legacyValidate).Messages().Get(0)
+	tests := []struct {
+		name    string
+		json    string
+		wantErr []validate.Violation
+	}{
+		{
+			name: "valid",
+			json: `{"name": "abc", "count": 1, "tags": ["ab"], "account": {"id": 5, "code": "XY"}, "email": "e"}`,
+		},
+		{
+			name: "invalid",
+			json: `{"name": "AB", "count": 10, "tags": ["a", "a"], "account": {"id": 0, "code": "xy"}, "email": "AB"}`,
+			wantErr: []validate.Violation{
+				{Field: "name", Rule: "string.min_len", Message: "value length must be at least 3 characters"},
+				{Field: "name", Rule: "string.pattern", Message: "value does not match regex pattern `^[a-z]+$`"},
+				{Field: "count", Rule: "int32.gte_lt", Message: "value must be greater than or equal to 1 and less than 10"},
+				{Field: "tags", Rule: "repeated.unique", Message: "repeated value must contain unique items"},
+				{Field: "tags[0]", Rule: "string.min_len", Message: "value length must be at least 2 characters"},
+				{Field: "tags[1]", Rule: "string.min_len", Message: "value length must be at least 2 characters"},
+				{Field: "account.id", Rule: "uint32.gt_lte", Message: "value must be greater than 0 and less than or equal to 100"},
+				{Field: "account.code", Rule: "code.upper", Message: "code must be upper case"},
+				{Field: "account.code", Rule: "code.prefix", Message: "code must start with X"},
+				{Field: "account.code", Message: "`this != 'xy'` returned false"},
+				{Rule: "name_not_email", Message: "name must not equal email"},
+			},
+		},
+		{
+			name: "missing",
+			json: `{"name": "abc", "count": 1}`,
+			wantErr: []validate.Violation{
+				{Field: "contact", Rule: "required", Message: "exactly one field is required in oneof"},
+				{Field: "tags", Rule: "repeated.min_items", Message: "value must contain at least 1 item(s)"},
+				{Field: "account", Rule: "required", Message: "value is required"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := grpcinternal.ParseMessage([]byte(tt.json), input, protojson.UnmarshalOptions{}, true)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			var verr *validate.Error
+			require.ErrorAs(t, err, &verr)
+			require.Equal(t, tt.wantErr, verr.Violations)
+			_, err = grpcinternal.ParseMessage([]byte(tt.json), input, protojson.UnmarshalOptions{}, false)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestMergeRequest(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "request.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"name": "file", "tags": ["a"]}`), 0o600))
+	tests := []struct {
+		name    string
+		proto   string
+		args    []string
+		stdin   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "flags",
+			proto: nestedProto,
+			args:  []string{"--name=a", "--billingAccount.count=1"},
+			want:  `{"name": "a", "billingAccount": {"count": 1}}`,
+		},
+		{
+			name:  "deep_merge",
+			proto: nestedProto,
+			args: []string{
+				`--json-data={"name": "x", "billing_account": {"display_name": "d", "count": 1}, "tags": ["a"], "labels": {"a": "1"}}`,
+				"--billingAccount.count=2", "--tags=b", "--labels=b=2",
+			},
+			want: `{"name": "x", "billingAccount": {"display_name": "d", "count": 2}, "tags": ["b"], "labels": {"a": "1", "b": "2"}}`,
+		},
+		{
+			name:  "file",
+			proto: nestedProto,
+			args:  []string{"--json-data=@" + file, "--name=flag"},
+			want:  `{"name": "flag", "tags": ["a"]}`,
+		},
+		{
+			name:  "stdin",
+			proto: nestedProto,
+			args:  []string{"--json-data=-"},
+			stdin: `{"name": "stdin", "counts": [18446744073709551615]}`,
+			want:  `{"name": "stdin", "counts": [18446744073709551615]}`,
+		},
+		{
+			name:  "oneof",
+			proto: behaviorProto,
+			args:  []string{`--json-data={"name": "a", "email": "e"}`, "--phone=p"},
+			want:  `{"name": "a", "phone": "p"}`,
+		},
+		{
+			name:    "invalid",
+			proto:   nestedProto,
+			args:    []string{"--json-data={"},
+			wantErr: "invalid --json-data: unexpected EOF",
+		},
+		{
+			name:  "yaml",
+			proto: nestedProto,
+			args:  []string{"--yaml-data=name: yaml\nbilling_account:\n  display_name: d\n", "--billingAccount.count=2"},
+			want:  `{"name": "yaml", "billingAccount": {"display_name": "d", "count": 2}}`,
+		},
+		{
+			name:  "text",
+			proto: nestedProto,
+			args:  []string{`--text-data=name: "text" billing_account { display_name: "d" }`, "--tags=a"},
+			want:  `{"name": "text", "billingAccount": {"displayName": "d"}, "tags": ["a"]}`,
+		},
+		{
+			name:    "multiple",
+			proto:   nestedProto,
+			args:    []string{"--yaml-data=name: a\n---\nname: b\n"},
+			wantErr: "Get takes a single request, got 2",
+		},
+		{
+			name:    "exclusive",
+			proto:   nestedProto,
+			args:    []string{"--yaml-data=name: a", "--json-data={}"},
+			wantErr: "if any flags in the group [json-data yaml-data text-data] are set none of the others can be; [json-data yaml-data] were all set",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stderr bytes.Buffer
+			cmd.SetErr(&stderr)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetArgs(append([]string{"Library", "Get", "--show-request"}, tt.args...))
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, tt.proto))))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, stderr.String())
+		})
+	}
+}
+
+const shadowedProto = `
+name: "shadowed.proto"
+package: "shadowed"
+syntax: "proto3"
+message_type: {
+	name: "Request"
+	field: { name: "name" number: 1 type: TYPE_STRING json_name: "name" }
+	field: { name: "output" number: 2 type: TYPE_STRING json_name: "output" }
+	field: { name: "raw_output" number: 3 type: TYPE_BOOL json_name: "rawOutput" }
+	field: { name: "columns" number: 4 type: TYPE_STRING json_name: "columns" oneof_index: 0 }
+	field: { name: "rows" number: 5 type: TYPE_STRING json_name: "rows" oneof_index: 0 }
+	oneof_decl: { name: "layout" }
+}
+service: {
+	name: "Library"
+	method: { name: "Get" input_type: ".shadowed.Request" output_type: ".shadowed.Request" }
+}
+`
+
+func TestShadowedFlags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		strategy NamingStrategy
+		args     []string
+		want     string
+		wantHelp string
+	}{
+		{
+			name:     "default",
+			args:     []string{"--name=a", "--rows=r", "--json-data", `{"output": "o", "columns": "c"}`, "--output=yaml", "--rawOutput=true"},
+			want:     `{"name": "a", "output": "o", "rows": "r", "rawOutput": true}`,
+			wantHelp: "The field(s) columns, output have no flag, as they have the name of a flag of the command. Set them with --json-data.",
+		},
+		{
+			name:     "kebab_case",
+			strategy: KebabCaseNaming(),
+			args:     []string{"--name=a", "--json-data", `{"output": "o", "raw_output": true}`, "--raw-output"},
+			want:     `{"name": "a", "output": "o", "raw_output": true}`,
+			wantHelp: "The field(s) columns, output, raw-output have no flag, as they have the name of a flag of the command. Set them with --json-data.",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			file := fileDescriptor(t, shadowedProto)
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stderr bytes.Buffer
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(append([]string{"Library", "Get", "--show-request"}, tt.args...))
+			require.NoError(t, BuildCommand(cmd, WithNamingStrategy(tt.strategy), WithFileDescriptors(file)))
+			require.NoError(t, cmd.ExecuteContext(context.Background()))
+			require.JSONEq(t, tt.want, stderr.String())
+
+			help := &cobra.Command{Use: "root"}
+			var stdout bytes.Buffer
+			help.SetOut(&stdout)
+			help.SetArgs([]string{"Library", "Get", "--help"})
+			require.NoError(t, BuildCommand(help, WithNamingStrategy(tt.strategy), WithFileDescriptors(file)))
+			require.NoError(t, help.ExecuteContext(context.Background()))
+			require.Contains(t, stdout.String(), tt.wantHelp)
+		})
+	}
+}
+
+func TestOptionOrder(t *testing.T) {
+	t.Parallel()
+	cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"library", "get", "--help"})
+	require.NoError(t, BuildCommand(cmd,
+		WithFileDescriptors(fileDescriptor(t, shadowedProto)),
+		WithNamingStrategy(KebabCaseNaming()),
+		WithOutputFormat("yaml"),
+	))
+	require.NoError(t, cmd.ExecuteContext(context.Background()))
+	require.Contains(t, stdout.String(), "root library get [flags]")
+	require.Contains(t, stdout.String(), `(default "yaml")`)
+}
+
+const streamingProto = `
+name: "streaming.proto"
+package: "streaming"
+syntax: "proto3"
+message_type: {
+	name: "Request"
+	field: { name: "name" number: 1 type: TYPE_STRING json_name: "name" }
+	field: { name: "payload" number: 2 type: TYPE_BYTES json_name: "payload" }
+	field: { name: "count" number: 3 type: TYPE_INT32 json_name: "count" }
+}
+service: {
+	name: "Library"
+	method: { name: "Upload" input_type: ".streaming.Request" output_type: ".streaming.Request" client_streaming: true }
+	method: { name: "Watch" input_type: ".streaming.Request" output_type: ".streaming.Request" server_streaming: true }
+	method: {
+		name: "Chat" input_type: ".streaming.Request" output_type: ".streaming.Request" client_streaming: true server_streaming: true
+	}
+}
+`
+
+// serveStreaming serves the Library service of streamingProto: Upload returns the last request, Watch returns its
+// request count times and Chat returns every request. A request named fail ends the call with an error.
+func serveStreaming(t *testing.T, file protoreflect.FileDescriptor) string {
+	t.Helper()
+	md := file.Messages().ByName("Request")
+	recv := func(stream grpc.ServerStream) (*dynamicpb.Message, error) {
+		msg := dynamicpb.NewMessage(md)
+		if err := stream.RecvMsg(msg); err != nil {
+			return nil, err
+		}
+		if msg.Get(md.Fields().ByName("name")).String() == "fail" {
+			return nil, status.Error(codes.FailedPrecondition, "request failed")
+		}
+		return msg, nil
+	}
+	service := &grpc.ServiceDesc{
+		ServiceName: "streaming.Library",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{
+			{
+				StreamName:    "Upload",
+				ClientStreams: true,
+				Handler: func(_ interface{}, stream grpc.ServerStream) error {
+					last := dynamicpb.NewMessage(md)
+					for {
+						msg, err := recv(stream)
+						if errors.Is(err, io.EOF) {
+							return stream.SendMsg(last)
+						}
+						if err != nil {
+							return err
+						}
+						last = msg
+					}
+				},
+			},
+			{
+				StreamName:    "Watch",
+				ServerStreams: true,
+				Handler: func(_ interface{}, stream grpc.ServerStream) error {
+					msg, err := recv(stream)
+					if err != nil {
+						return err
+					}
+					for i := int64(0); i < msg.Get(md.Fields().ByName("count")).Int(); i++ {
+						if err := stream.SendMsg(msg); err != nil {
+							return err
+						}
+					}
+					return nil
+				},
+			},
+			{
+				StreamName:    "Chat",
+				ClientStreams: true,
+				ServerStreams: true,
+				Handler: func(_ interface{}, stream grpc.ServerStream) error {
+					for {
+						msg, err := recv(stream)
+						if errors.Is(err, io.EOF) {
+							return nil
+						}
+						if err != nil {
+							return err
+						}
+						if err := stream.SendMsg(msg); err != nil {
+							return err
+						}
+					}
+				},
+			},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	port, err := example.ServeRand(ctx, func(server *grpc.Server) {
+		server.RegisterService(service, nil)
+	})
+	require.NoError(t, err)
+	return fmt.Sprintf("http://localhost:%d", port)
+}
+
+func TestStreaming(t *testing.T) {
+	t.Parallel()
+	file := fileDescriptor(t, streamingProto)
+	addr := serveStreaming(t, file)
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "server_streaming_flags",
+			args: []string{"Library", "Watch", "--name=foo", "--count=2"},
+			want: `{"name":"foo","count":2}` + "\n" + `{"name":"foo","count":2}`,
+		},
+		{
+			name: "server_streaming_input_flag",
+			args: []string{"Library", "Watch", "--json-data", `{"name": "bar", "count": 1}`, "--name=foo"},
+			want: `{"name":"foo","count":1}`,
+		},
+		{
+			name: "client_streaming_flags",
+			args: []string{"Library", "Upload", "--json-data", `[{"name": "a"}, {"name": "b"}]`, "--count=3"},
+			want: `{"name":"b","count":3}`,
+		},
+		{
+			name:  "bidi_streaming_flags",
+			args:  []string{"Library", "Chat", "--count=3"},
+			stdin: `[{"name": "a"}, {"name": "b", "count": 1}]`,
+			want:  `{"name":"a","count":3}` + "\n" + `{"name":"b","count":3}`,
+		},
+		{
+			name:    "server_streaming_error",
+			args:    []string{"Library", "Watch", "--name=fail"},
+			wantErr: "failed_precondition: request failed",
+		},
+		{
+			name:    "client_streaming_error",
+			args:    []string{"Library", "Upload", "--json-data", `[{"name": "a"}, {"name": "fail"}, {"name": "b"}]`},
+			wantErr: "failed_precondition: request failed",
+		},
+		{
+			name:    "bidi_streaming_error",
+			args:    []string{"Library", "Chat"},
+			stdin:   `[{"name": "a"}, {"name": "fail"}, {"name": "b"}]`,
+			want:    `{"name":"a"}`,
+			wantErr: "failed_precondition: request failed",
+		},
+		{
+			// The call ends while the server still sends responses that nothing reads.
+			name:    "print_error",
+			args:    []string{"Library", "Watch", "--name=foo", "--count=1000", "--jq", `error("stop")`},
+			wantErr: "stop",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "grpctl", SilenceErrors: true, SilenceUsage: true}
+			var stdout bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetArgs(append([]string{"--address=" + addr, "--protocol=grpc", "-o", "json-compact"}, tt.args...))
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(file)))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.want == "" {
+				require.Empty(t, stdout.String())
+				return
+			}
+			// protojson randomly adds spaces to its output.
+			require.Equal(t, tt.want+"\n", regexp.MustCompile(`[ \x{00a0}]+`).ReplaceAllString(stdout.String(), ""))
+		})
+	}
+}
+
+func TestShowStreamingRequests(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		args  []string
+		stdin string
+		want  []string
+	}{
+		{
+			name: "client_streaming",
+			args: []string{"Upload", "--json-data", `[{"name": "a"}, {"name": "b", "count": 1}]`, "--count=2"},
+			want: []string{`{"name": "a", "count": 2}`, `{"name": "b", "count": 2}`},
+		},
+		{
+			name: "server_streaming_flags",
+			args: []string{"Watch", "--name=a"},
+			want: []string{`{"name": "a"}`},
+		},
+		{
+			name:  "bidi_streaming_stdin",
+			args:  []string{"Chat", "--count=1"},
+			stdin: `[{"name": "a"}, {"name": "b"}]`,
+			want:  []string{`{"name": "a", "count": 1}`, `{"name": "b", "count": 1}`},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stderr bytes.Buffer
+			cmd.SetErr(&stderr)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetArgs(append([]string{"Library", tt.args[0], "--show-request"}, tt.args[1:]...))
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, streamingProto))))
+			require.NoError(t, cmd.ExecuteContext(context.Background()))
+			decoder := json.NewDecoder(&stderr)
+			for _, want := range tt.want {
+				var got json.RawMessage
+				require.NoError(t, decoder.Decode(&got))
+				require.JSONEq(t, want, string(got))
+			}
+			require.False(t, decoder.More())
+		})
+	}
+}
+
+func TestReadMessages(t *testing.T) {
+	t.Parallel()
+	file := filepath.Join(t.TempDir(), "payload.bin")
+	require.NoError(t, os.WriteFile(file, []byte{0, 1, 2}, 0o600))
+	vars := filepath.Join(t.TempDir(), "vars.yaml")
+	require.NoError(t, os.WriteFile(vars, []byte("name: n1\nsuffix: s\n"), 0o600))
+	method := fileDescriptor(t, streamingProto).Services().Get(0).Methods().Get(0)
+	tests := []struct {
+		name    string
+		input   inputFlags
+		stdin   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "none",
+			input: inputFlags{},
+			want:  `null`,
+		},
+		{
+			name:  "json",
+			input: inputFlags{json: "-"},
+			stdin: fmt.Sprintf(`[{"name": "a"}, {"name": "b", "payload": "@%s"}]`, file),
+			want:  `[{"name": "a"}, {"name": "b", "payload": "AAEC"}]`,
+		},
+		{
+			name:  "yaml",
+			input: inputFlags{yaml: "-"},
+			stdin: "name: a\n---\n---\nname: b\npayload: !!binary AAEC\n",
+			want:  `[{"name": "a"}, {"name": "b", "payload": "AAEC"}]`,
+		},
+		{
+			name:  "text",
+			input: inputFlags{text: "-"},
+			stdin: "name: \"a\"\n\nname: \"b\" payload: \"\\000\\001\\002\"\n",
+			want:  `[{"name": "a"}, {"name": "b", "payload": "AAEC"}]`,
+		},
+		{
+			name:  "vars",
+			input: inputFlags{json: "-", vars: vars},
+			stdin: `[{"name": "{{ .name }}-${suffix}"}, {"name": "${suffix}"}]`,
+			want:  `[{"name": "n1-s"}, {"name": "s"}]`,
+		},
+		{
+			name:  "env",
+			input: inputFlags{yaml: `name: ${PATH}$${PATH}{{ env "PATH" }}`, expand: true},
+			want:  fmt.Sprintf(`[{"name": "%[1]s${PATH}%[1]s"}]`, os.Getenv("PATH")),
+		},
+		{
+			name:  "literal",
+			input: inputFlags{json: `{"name": "cost is ${PRICE} {{ .price }}"}`},
+			want:  `[{"name": "cost is ${PRICE} {{ .price }}"}]`,
+		},
+		{
+			name:    "undefined_variable",
+			input:   inputFlags{json: `{"name": "${GRPCTL_UNDEFINED}"}`, vars: vars},
+			wantErr: "invalid --json-data: undefined variable(s) GRPCTL_UNDEFINED",
+		},
+		{
+			name:    "undefined_template_variable",
+			input:   inputFlags{json: `{"name": "{{ .missing }}"}`, vars: vars},
+			wantErr: `invalid --json-data: template: request:1:13: executing "request" at <.missing>: map has no entry for key "missing"`,
+		},
+		{
+			name:    "missing_vars",
+			input:   inputFlags{json: `{}`, vars: filepath.Join(filepath.Dir(vars), "missing.yaml")},
+			wantErr: "invalid --vars: open " + filepath.Join(filepath.Dir(vars), "missing.yaml") + ": no such file or directory",
+		},
+		{
+			name:    "invalid_yaml",
+			input:   inputFlags{yaml: "- a"},
+			wantErr: "invalid --yaml-data: YAML document 1 is not a mapping",
+		},
+		{
+			name:    "invalid_text",
+			input:   inputFlags{text: "nme: 1"},
+			wantErr: `invalid --text-data: proto: (line 1:1): unknown field: nme`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{}
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			messages, err := tt.input.readMessages(cmd, method)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				// protobuf randomizes the spaces in its errors.
+				// prototext randomly adds spaces to its output.
+				spaces := regexp.MustCompile(`[ \x{00a0}]+`)
+				require.Equal(t, spaces.ReplaceAllString(tt.wantErr, " "), spaces.ReplaceAllString(err.Error(), " "))
+				return
+			}
+			require.NoError(t, err)
+			got, err := json.Marshal(messages)
+			require.NoError(t, err)
+			require.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestExpandFunctions(t *testing.T) {
+	t.Parallel()
+	method := fileDescriptor(t, streamingProto).Services().Get(0).Methods().Get(0)
+	cmd := &cobra.Command{}
+	input := inputFlags{text: "name: \"{{ uuid }}\"\nname: \"{{ uuid }}\"\nname: \"{{ now | rfc3339 }}\"\nname: \"{{ now | unix }}\"", expand: true}
+	messages, err := input.readMessages(cmd, method)
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	names := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		name, ok := msg["name"].(string)
+		require.True(t, ok)
+		names = append(names, name)
+	}
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	require.Regexp(t, uuid, names[0])
+	require.Regexp(t, uuid, names[1])
+	require.NotEqual(t, names[0], names[1])
+	now, err := time.Parse(time.RFC3339, names[2])
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), now, time.Minute)
+	unix, err := strconv.ParseInt(names[3], 10, 64)
+	require.NoError(t, err)
+	require.InDelta(t, now.Unix(), unix, 60)
+}
+
+func TestInteractive(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		proto       string
+		args        []string
+		stdin       string
+		wantPrompts []string
+		want        string
+		wantErr     string
+	}{
+		{
+			name:  "required and oneof",
+			proto: behaviorProto,
+			stdin: "\nn1\ny\nacc\nfax\nphone\n555\ny\n",
+			wantPrompts: []string{
+				"name (string, required): name is required\n",
+				"Set billingAccount (behavior.Account)? [y/N]: ",
+				"billingAccount.accountId (string, required): ",
+				"contact (one of email, phone, address): expected one of email, phone, address\n",
+				"phone (string): ",
+			},
+			want: `{"billingAccount":{"accountId":"acc"},"name":"n1","phone":"555"}`,
+		},
+		{
+			name:  "lists maps and enums",
+			proto: nestedProto,
+			args:  []string{"--name=flag", "--tags=a"},
+			stdin: "\ny\nd\nx\n2\n\n\nb\n\n1,2\n\n\nk=v\n\n1=3.5\n\n\nBILLING_STATE_OPEN\nBILLING_STATE_CLOSED\n\n\n",
+			wantPrompts: []string{
+				"name (string) [flag]: ",
+				"billingAccount.count (int32): invalid value: ",
+				"Keep 1 element(s) of tags? [Y/n]: tags[1] (string, empty to finish): tags[2] (string, empty to finish): ",
+				"counts[0] (uint32, empty to finish): counts[2] (uint32, empty to finish): ",
+				"Add accounts[0] (nested.Account)? [y/N]: ",
+				"labels (map[string]string, key=value, empty to finish): ",
+				"  1: BILLING_STATE_OPEN - The account can be billed.\n",
+				"states[0] (enum, empty to finish): ",
+			},
+			want: `{"billingAccount":{"count":2,"displayName":"d"},"counts":[1,2],"labels":{"k":"v"},"limits":{"1":3.5},` +
+				`"name":"flag","state":"BILLING_STATE_OPEN","states":["BILLING_STATE_CLOSED"],"tags":["a","b"]}`,
+		},
+		{
+			name:        "not sent",
+			proto:       behaviorProto,
+			stdin:       "n1\n\n\nn\n",
+			wantPrompts: []string{"Send request? [Y/n]: "},
+		},
+		{
+			name:    "input ended",
+			proto:   behaviorProto,
+			stdin:   "n1\n",
+			wantErr: "interactive input ended: unexpected EOF",
+		},
+		{
+			name:    "streaming",
+			proto:   streamingProto,
+			args:    []string{"--json-data={}"},
+			wantErr: "--interactive is not supported for client streaming methods",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			method := []string{"Library", "Get"}
+			if tt.proto == streamingProto {
+				method = []string{"Library", "Upload"}
+			}
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stderr bytes.Buffer
+			cmd.SetErr(&stderr)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetArgs(append(append(method, "--interactive", "--show-request"), tt.args...))
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, tt.proto))))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for _, prompt := range tt.wantPrompts {
+				require.Contains(t, stderr.String(), prompt)
+			}
+			_, shown, sent := strings.Cut(stderr.String(), "Send request? [Y/n]: ")
+			require.True(t, sent)
+			if tt.want == "" {
+				require.Empty(t, shown)
+				return
+			}
+			require.JSONEq(t, tt.want, shown)
+		})
+	}
+}
+
+// editorScript returns an editor command that saves the file it is given as seen<n> in dir and replaces it with
+// edits[n-1], where n counts the times it was run.
+func editorScript(t *testing.T, dir string, edits ...string) string {
+	t.Helper()
+	for i, edit := range edits {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("edit%d", i+1)), []byte(edit), 0o600))
+	}
+	script := `n=$(($(cat "$0.count" 2>/dev/null || echo 0) + 1)); echo $n > "$0.count"; cp "$1" "$(dirname "$0")/seen$n"; cp "$(dirname "$0")/edit$n" "$1"`
+	path := filepath.Join(dir, "editor")
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o600))
+	return "sh " + path
+}
+
+func TestEdit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		proto    string
+		args     []string
+		edits    []string
+		stdin    string
+		wantSeen string
+		want     string
+		wantOut  []string
+		wantErr  string
+	}{
+		{
+			name:     "template",
+			proto:    behaviorProto,
+			edits:    []string{`{"name": "edited"}`},
+			wantSeen: `{"name": "string", "billingAccount": {"accountId": "string"}, "email": "string"}`,
+			want:     `{"name": "edited"}`,
+		},
+		{
+			name:     "flags",
+			proto:    behaviorProto,
+			args:     []string{"--name=flag", "--phone=555"},
+			edits:    []string{`{"name": "edited", "phone": "555"}`},
+			wantSeen: `{"name": "flag", "phone": "555"}`,
+			want:     `{"name": "edited", "phone": "555"}`,
+		},
+		{
+			name:    "reopened on errors",
+			proto:   behaviorProto,
+			args:    []string{"--name=flag"},
+			edits:   []string{`{"name": "flag"`, `{"phone": "555"}`, `{"name": "flag", "unknown": 1}`, `{"name": "flag"}`},
+			stdin:   "\ny\nyes\n",
+			want:    `{"name": "flag"}`,
+			wantOut: []string{"Error: unexpected EOF\n", `Error: required flag(s) "name" not set`, `unknown field "unknown"`, "Edit the request again? [Y/n]: "},
+		},
+		{
+			name:    "not edited again",
+			proto:   behaviorProto,
+			edits:   []string{`{}`},
+			stdin:   "n\n",
+			wantErr: `required flag(s) "name" not set`,
+		},
+		{
+			name:    "emptied",
+			proto:   behaviorProto,
+			edits:   []string{" \n"},
+			wantOut: []string{"the request is empty, not sending it\n"},
+		},
+		{
+			name:    "streaming",
+			proto:   streamingProto,
+			wantErr: "--edit is not supported for client streaming methods",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			method := []string{"Library", "Get"}
+			if tt.proto == streamingProto {
+				method = []string{"Library", "Upload"}
+			}
+			dir := t.TempDir()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stderr bytes.Buffer
+			cmd.SetErr(&stderr)
+			cmd.SetIn(strings.NewReader(tt.stdin))
+			cmd.SetArgs(append(append(method, "--edit", "--show-request"), tt.args...))
+			opts := []CommandOption{WithEditor(editorScript(t, dir, tt.edits...)), WithFileDescriptors(fileDescriptor(t, tt.proto))}
+			require.NoError(t, BuildCommand(cmd, opts...))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantSeen != "" {
+				seen, err := os.ReadFile(filepath.Join(dir, "seen1"))
+				require.NoError(t, err)
+				require.JSONEq(t, tt.wantSeen, string(seen))
+			}
+			out := strings.ReplaceAll(stderr.String(), " ", " ")
+			for _, want := range tt.wantOut {
+				require.Contains(t, out, want)
+			}
+			if tt.want == "" {
+				require.Empty(t, strings.TrimSpace(strings.Join(strings.Split(out, "\n")[1:], "\n")))
+				return
+			}
+			require.JSONEq(t, tt.want, out[strings.Index(out, "{\n"):])
+		})
+	}
+}
+
+const scalarsProto = `
+name: "scalars.proto"
+package: "scalars"
+syntax: "proto3"
+message_type: {
+	name: "Request"
+	field: { name: "f_bool" number: 1 type: TYPE_BOOL json_name: "fBool" }
+	field: { name: "f_int32" number: 2 type: TYPE_INT32 json_name: "fInt32" }
+	field: { name: "f_sint32" number: 3 type: TYPE_SINT32 json_name: "fSint32" }
+	field: { name: "f_sfixed32" number: 4 type: TYPE_SFIXED32 json_name: "fSfixed32" }
+	field: { name: "f_uint32" number: 5 type: TYPE_UINT32 json_name: "fUint32" }
+	field: { name: "f_fixed32" number: 6 type: TYPE_FIXED32 json_name: "fFixed32" }
+	field: { name: "f_int64" number: 7 type: TYPE_INT64 json_name: "fInt64" }
+	field: { name: "f_sint64" number: 8 type: TYPE_SINT64 json_name: "fSint64" }
+	field: { name: "f_sfixed64" number: 9 type: TYPE_SFIXED64 json_name: "fSfixed64" }
+	field: { name: "f_uint64" number: 10 type: TYPE_UINT64 json_name: "fUint64" }
+	field: { name: "f_fixed64" number: 11 type: TYPE_FIXED64 json_name: "fFixed64" }
+	field: { name: "f_float" number: 12 type: TYPE_FLOAT json_name: "fFloat" }
+	field: { name: "f_double" number: 13 type: TYPE_DOUBLE json_name: "fDouble" }
+	field: { name: "f_bytes" number: 14 type: TYPE_BYTES json_name: "fBytes" }
+	field: { name: "crc32c" number: 15 type: TYPE_UINT32 json_name: "crc32c" }
+	field: { name: "f_optional" number: 16 type: TYPE_STRING json_name: "fOptional" oneof_index: 1 proto3_optional: true }
+	field: { name: "old_choice" number: 17 type: TYPE_STRING json_name: "oldChoice" oneof_index: 0 options: { deprecated: true } }
+	field: { name: "new_choice" number: 18 type: TYPE_INT32 json_name: "newChoice" oneof_index: 0 }
+	field: { name: "counts" number: 19 label: LABEL_REPEATED type: TYPE_FIXED64 json_name: "counts" }
+	oneof_decl: { name: "choice" }
+	oneof_decl: { name: "_f_optional" }
+}
+service: {
+	name: "Library"
+	method: { name: "Get" input_type: ".scalars.Request" output_type: ".scalars.Request" }
+}
+`
+
+func TestExample(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		proto string
+		want  string
+	}{
+		{
+			name:  "scalars",
+			proto: scalarsProto,
+			want: `{"fBool": true, "fInt32": 1, "fSint32": 1, "fSfixed32": 1, "fUint32": 1, "fFixed32": 1,
+				"fInt64": "1", "fSint64": "1", "fSfixed64": "1", "fUint64": "1", "fFixed64": "1", "fFloat": 1.1, "fDouble": 1.1,
+				"fBytes": "ZkJ5dGVz", "crc32c": 1, "fOptional": "string", "newChoice": 1, "counts": ["1"]}`,
+		},
+		{
+			name:  "nested",
+			proto: nestedProto,
+			want: `{"name": "string", "billingAccount": {"displayName": "string", "count": 1, "parent": {}},
+				"tags": ["string"], "counts": [1], "accounts": [{"displayName": "string", "count": 1, "parent": {}}],
+				"labels": {"key": "string"}, "limits": {"1": 1.1}, "owners": {"key": {"displayName": "string", "count": 1, "parent": {}}},
+				"state": "BILLING_STATE_OPEN", "states": ["BILLING_STATE_OPEN"]}`,
+		},
+		{
+			name:  "field_behavior",
+			proto: behaviorProto,
+			want:  `{"name": "string", "billingAccount": {"accountId": "string"}, "email": "string"}`,
+		},
+		{
+			name:  "well_known",
+			proto: wellKnownProto,
+			want: `{"createTime": "2006-01-02T15:04:05Z", "ttl": "1s", "updateMask": "string", "limit": 1, "enabled": true,
+				"metadata": {"google.protobuf.Struct": "supports arbitrary JSON objects"},
+				"value": {"google.protobuf.Value": "supports arbitrary JSON"},
+				"detail": {"@type": "type.googleapis.com/google.protobuf.Empty", "value": {}},
+				"payload": "cGF5bG9hZA==", "blob": "dmFsdWU=", "chunks": ["Y2h1bmtz"], "parent": {}}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fd := fileDescriptor(t, tt.proto)
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stdout bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetArgs([]string{"Library", "Get", "--example", "--address=unused"})
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fd)))
+			require.NoError(t, cmd.ExecuteContext(context.Background()))
+			require.JSONEq(t, tt.want, stdout.String())
+			_, err := grpcinternal.ParseMessage(stdout.Bytes(), fd.Services().Get(0).Methods().Get(0).Input(), protojson.UnmarshalOptions{}, false)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestExampleFlagCompletion(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		flag string
+		want string
+	}{
+		{flag: "--name", want: "string"},
+		{flag: "--tags", want: "string"},
+		{flag: "--counts", want: "1"},
+		{flag: "--labels", want: "key=string"},
+		{flag: "--billingAccount.count", want: "1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.flag, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root"}
+			var b bytes.Buffer
+			cmd.SetOut(&b)
+			cmd.SetArgs([]string{"__complete", "Library", "Get", tt.flag, ""})
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, nestedProto))))
+			require.NoError(t, cmd.ExecuteContext(context.Background()))
+			require.Equal(t, tt.want+"\n:0\n", b.String())
+		})
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	t.Parallel()
+	md := fileDescriptor(t, nestedProto).Messages().Get(0)
+	messages := make([]proto.Message, 0, 2)
+	for _, j := range []string{
+		`{"name": "a", "billingAccount": {"count": 2}, "tags": ["x", "y"], "state": "BILLING_STATE_OPEN"}`,
+		`{"name": "b\nc", "labels": {"k": "v"}}`,
+	} {
+		msg := dynamicpb.NewMessage(md)
+		require.NoError(t, protojson.Unmarshal([]byte(j), msg))
+		messages = append(messages, msg)
+	}
+	tests := []struct {
+		format output.Format
+		stream bool
+		want   string
+	}{
+		{format: output.JSONCompact, want: `{"name":"a","billingAccount":{"count":2},"tags":["x","y"],"state":"BILLING_STATE_OPEN"}` + "\n"},
+		{
+			format: output.JSONCompact,
+			stream: true,
+			want:   `{"name":"a","billingAccount":{"count":2},"tags":["x","y"],"state":"BILLING_STATE_OPEN"}` + "\n" + `{"name":"b\nc","labels":{"k":"v"}}` + "\n",
+		},
+		{format: output.YAML, want: "name: a\nbillingAccount:\n  count: 2\ntags:\n  - x\n  - y\nstate: BILLING_STATE_OPEN\n"},
+		{
+			format: output.YAML,
+			stream: true,
+			want:   "---\nname: a\nbillingAccount:\n  count: 2\ntags:\n  - x\n  - y\nstate: BILLING_STATE_OPEN\n---\nname: |-\n  b\n  c\nlabels:\n  k: v\n",
+		},
+		{format: output.Text, want: "name: \"a\"\nbilling_account: {\n  count: 2\n}\ntags: \"x\"\ntags: \"y\"\nstate: BILLING_STATE_OPEN\n"},
+		{format: output.Binary, want: "\n\x01a\x12\x02\x10\x02\x1a\x01x\x1a\x01yH\x01"},
+		{format: output.Binary, stream: true, want: "\x0f\n\x01a\x12\x02\x10\x02\x1a\x01x\x1a\x01yH\x01\r\n\x03b\nc2\x06\n\x01k\x12\x01v"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(fmt.Sprintf("%s_stream_%t", tt.format, tt.stream), func(t *testing.T) {
+			t.Parallel()
+			var b bytes.Buffer
+			printer := output.NewPrinter(&b, tt.format, protojson.MarshalOptions{Resolver: protoregistry.GlobalTypes})
+			printer.Stream = tt.stream
+			for _, msg := range messages {
+				require.NoError(t, printer.Print(msg))
+				if !tt.stream {
+					break
+				}
+			}
+			require.NoError(t, printer.Close())
+			// protobuf randomizes the spaces in its text format.
+			got := strings.ReplaceAll(strings.ReplaceAll(b.String(), " ", " "), ":  ", ": ")
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOutputFlag(t *testing.T) {
+	t.Parallel()
+	port, err := example.ServeRand(context.Background(), func(server *grpc.Server) {
+		examplepb.RegisterFooAPIServer(server, &example.FooServer{})
+	})
+	require.NoError(t, err)
+	addr := fmt.Sprintf("http://localhost:%d", port)
+	tests := []struct {
+		name       string
+		args       []string
+		opts       []CommandOption
+		wantPrefix string
+		wantErr    string
+	}{
+		{
+			name:       "default",
+			args:       []string{"FooAPI", "Hello", "--message=blah"},
+			wantPrefix: "{\n \"message\":",
+		},
+		{
+			name:       "flag",
+			args:       []string{"FooAPI", "Hello", "--message=blah", "-o", "prototext"},
+			wantPrefix: "message: \"Incoming Message: blah",
+		},
+		{
+			name:       "option",
+			args:       []string{"FooAPI", "Hello", "--message=blah"},
+			opts:       []CommandOption{WithOutputFormat("yaml")},
+			wantPrefix: "message: \"Incoming Message: blah",
+		},
+		{
+			name:       "flag_overrides_option",
+			args:       []string{"FooAPI", "Hello", "--message=blah", "--output=json-compact"},
+			opts:       []CommandOption{WithOutputFormat("yaml")},
+			wantPrefix: `{"message":"Incoming Message: blah`,
+		},
+		{
+			name:    "unknown",
+			args:    []string{"FooAPI", "Hello", "--message=blah", "-o", "xml"},
+			wantErr: `invalid --output: unknown output format "xml", expected one of json, json-compact, yaml, prototext, binary, table`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			args := append([]string{"grpctl", "--address=" + addr}, tt.args...)
+			cmd := &cobra.Command{Use: "grpctl", SilenceErrors: true, SilenceUsage: true}
+			var b bytes.Buffer
+			cmd.SetOut(&b)
+			require.NoError(t, BuildCommand(cmd, append(tt.opts, WithArgs(args), WithReflection(args))...))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(strings.ReplaceAll(b.String(), ":  ", ": "), tt.wantPrefix), b.String())
+		})
+	}
+}
+
+func TestOutputFormatOption(t *testing.T) {
+	t.Parallel()
+	cmd := &cobra.Command{Use: "root"}
+	require.EqualError(t, BuildCommand(cmd, WithOutputFormat("xml")),
+		`unknown output format "xml", expected one of json, json-compact, yaml, prototext, binary, table`)
+}
+
+func TestOutputFilter(t *testing.T) {
+	t.Parallel()
+	md := fileDescriptor(t, nestedProto).Messages().Get(0)
+	messages := make([]proto.Message, 0, 2)
+	for _, j := range []string{`{"name": "a", "tags": ["x", "y"], "billingAccount": {"count": 2}}`, `{"name": "b"}`} {
+		msg := dynamicpb.NewMessage(md)
+		require.NoError(t, protojson.Unmarshal([]byte(j), msg))
+		messages = append(messages, msg)
+	}
+	tests := []struct {
+		name     string
+		format   output.Format
+		query    string
+		template string
+		raw      bool
+		want     string
+		wantErr  string
+	}{
+		{name: "query", format: output.JSON, query: ".name", want: "\"a\"\n\"b\"\n"},
+		{name: "raw", format: output.JSON, query: ".name", raw: true, want: "a\nb\n"},
+		{name: "raw_non_string", format: output.JSONCompact, query: ".tags", raw: true, want: "[\"x\",\"y\"]\nnull\n"},
+		{name: "multiple_results", format: output.JSON, query: ".tags[]?", want: "\"x\"\n\"y\"\n"},
+		{name: "object", format: output.JSON, query: "{name, count: .billingAccount.count}", want: "{\n \"count\": 2,\n \"name\": \"a\"\n}\n{\n \"count\": null,\n \"name\": \"b\"\n}\n"},
+		{name: "yaml", format: output.YAML, query: "{name}", want: "---\nname: a\n---\nname: b\n"},
+		{
+			name:     "template",
+			format:   output.JSON,
+			template: "{{ .name }}: {{ len .tags }}",
+			want:     "a: 2\n",
+			wantErr:  "template: output:1:16: executing \"output\" at <len .tags>: error calling len: reflect: call of reflect.Value.Type on zero Value",
+		},
+		{name: "query_error", format: output.JSON, query: ".name.first", wantErr: "jq: expected an object but got: string (\"a\")"},
+		{name: "table", format: output.Table, query: ".name", wantErr: "filtered output can't be written as table"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var filter *output.Filter
+			var err error
+			if tt.query != "" {
+				filter, err = output.ParseQuery(tt.query)
+			} else {
+				filter, err = output.ParseTemplate(tt.template)
+			}
+			require.NoError(t, err)
+			filter.Raw = tt.raw
+			var b bytes.Buffer
+			printer := output.NewPrinter(&b, tt.format, protojson.MarshalOptions{Resolver: protoregistry.GlobalTypes})
+			printer.Stream = true
+			if err := printer.SetFilter(filter); err != nil {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			for _, msg := range messages {
+				if err = printer.Print(msg); err != nil {
+					break
+				}
+			}
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, b.String())
+		})
+	}
+}
+
+func TestOutputFilterFlags(t *testing.T) {
+	t.Parallel()
+	port, err := example.ServeRand(context.Background(), func(server *grpc.Server) {
+		examplepb.RegisterFooAPIServer(server, &example.FooServer{})
+	})
+	require.NoError(t, err)
+	addr := fmt.Sprintf("http://localhost:%d", port)
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "jq",
+			args: []string{"--jq", `.message | split(" ")[:3] | join(" ")`},
+			want: "\"Incoming Message: blah\"\n",
+		},
+		{
+			name: "raw",
+			args: []string{"--jq", `.message | split(" ")[:3] | join(" ")`, "-r"},
+			want: "Incoming Message: blah\n",
+		},
+		{
+			name: "template",
+			args: []string{"--template", `{{ slice .message 0 22 }}`},
+			want: "Incoming Message: blah\n",
+		},
+		{
+			name:    "invalid_jq",
+			args:    []string{"--jq", ".["},
+			wantErr: "invalid --jq: unexpected EOF",
+		},
+		{
+			name:    "invalid_template",
+			args:    []string{"--template", "{{ .message"},
+			wantErr: `invalid --template: template: output:1: unclosed action`,
+		},
+		{
+			name:    "format",
+			args:    []string{"--jq", ".", "-o", "prototext"},
+			wantErr: "--jq: filtered output can't be written as prototext",
+		},
+		{
+			name:    "exclusive",
+			args:    []string{"--jq", ".", "--template", "{{ . }}"},
+			wantErr: "if any flags in the group [jq template] are set none of the others can be; [jq template] were all set",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			args := append([]string{"grpctl", "--address=" + addr, "FooAPI", "Hello", "--message=blah"}, tt.args...)
+			cmd := &cobra.Command{Use: "grpctl", SilenceErrors: true, SilenceUsage: true}
+			var b bytes.Buffer
+			cmd.SetOut(&b)
+			require.NoError(t, BuildCommand(cmd, WithArgs(args), WithReflection(args)))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, b.String())
+		})
+	}
+}
+
+const listProto = `
+name: "list.proto"
+package: "list"
+syntax: "proto3"
+dependency: ["google/protobuf/timestamp.proto"]
+message_type: {
+	name: "ListBooksResponse"
+	field: { name: "books" number: 1 label: LABEL_REPEATED type: TYPE_MESSAGE type_name: ".list.Book" json_name: "books" }
+	field: { name: "next_page_token" number: 2 type: TYPE_STRING json_name: "nextPageToken" }
+}
+message_type: {
+	name: "Book"
+	field: { name: "name" number: 1 type: TYPE_STRING json_name: "name" }
+	field: { name: "state" number: 2 type: TYPE_ENUM type_name: ".list.State" json_name: "state" }
+	field: { name: "create_time" number: 3 type: TYPE_MESSAGE type_name: ".google.protobuf.Timestamp" json_name: "createTime" }
+	field: { name: "author" number: 4 type: TYPE_MESSAGE type_name: ".list.Author" json_name: "author" }
+	field: { name: "tags" number: 5 label: LABEL_REPEATED type: TYPE_STRING json_name: "tags" }
+}
+message_type: {
+	name: "Author"
+	field: { name: "display_name" number: 1 type: TYPE_STRING json_name: "displayName" }
+	field: { name: "mentor" number: 2 type: TYPE_MESSAGE type_name: ".list.Author" json_name: "mentor" }
+}
+enum_type: {
+	name: "State"
+	value: { name: "STATE_UNSPECIFIED" number: 0 }
+	value: { name: "PUBLISHED" number: 1 }
+}
+service: {
+	name: "Library"
+	method: { name: "ListBooks" input_type: ".list.Book" output_type: ".list.ListBooksResponse" }
+}
+`
+
+func TestTableOutput(t *testing.T) {
+	t.Parallel()
+	fd := fileDescriptor(t, listProto)
+	pages := []string{
+		`{"books": [{"name": "shelves/1/books/1", "state": "PUBLISHED", "createTime": "2006-01-02T15:04:05Z", "author": {"displayName": "Ann"}},
+			{"name": "shelves/1/books/2", "tags": ["a", "b"], "author": {"displayName": "Bob", "mentor": {"displayName": "Ann"}}}], "nextPageToken": "next"}`,
+		`{"books": [{"name": "shelves/1/books/3"}]}`,
+	}
+	scalars := fileDescriptor(t, scalarsProto).Messages().Get(0)
+	tests := []struct {
+		name    string
+		md      protoreflect.MessageDescriptor
+		columns []string
+		width   int
+		want    string
+		wantErr string
+	}{
+		{
+			name: "all_fields",
+			md:   fd.Messages().Get(0),
+			want: "NAME               STATE      CREATE_TIME           AUTHOR                                                TAGS\n" +
+				"shelves/1/books/1  PUBLISHED  2006-01-02T15:04:05Z  {\"displayName\":\"Ann\"}\n" +
+				"shelves/1/books/2                                   {\"displayName\":\"Bob\",\"mentor\":{\"displayName\":\"Ann\"}}  [\"a\",\"b\"]\n" +
+				"shelves/1/books/3\n",
+		},
+		{
+			name:    "columns",
+			md:      fd.Messages().Get(0),
+			columns: []string{"name", "author.displayName", "author.mentor.display_name", "create_time"},
+			want: "NAME               AUTHOR.DISPLAYNAME  AUTHOR.MENTOR.DISPLAY_NAME  CREATE_TIME\n" +
+				"shelves/1/books/1  Ann                                             2006-01-02T15:04:05Z\n" +
+				"shelves/1/books/2  Bob                 Ann\n" +
+				"shelves/1/books/3\n",
+		},
+		{
+			name:    "width",
+			md:      fd.Messages().Get(0),
+			columns: []string{"name", "author", "state"},
+			width:   40,
+			want: "NAME               AUTHOR      STATE\n" +
+				"shelves/1/books/1  {\"display…  PUBLISHED\n" +
+				"shelves/1/books/2  {\"display…\n" +
+				"shelves/1/books/3\n",
+		},
+		{
+			name:    "unknown_column",
+			md:      fd.Messages().Get(0),
+			columns: []string{"author.name"},
+			wantErr: `unknown column "author.name", expected one of displayName, mentor`,
+		},
+		{
+			name:    "not_a_list",
+			md:      scalars,
+			columns: []string{"fInt32", "f_bool"},
+			want:    "FINT32  F_BOOL\n1       true\n1       true\n",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var b bytes.Buffer
+			printer := output.NewPrinter(&b, output.Table, protojson.MarshalOptions{Resolver: protoregistry.GlobalTypes})
+			printer.Stream = true
+			printer.Width = tt.width
+			if err := printer.SetColumns(tt.md, tt.columns); tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			for _, page := range pages {
+				msg := dynamicpb.NewMessage(tt.md)
+				if tt.md == scalars {
+					page = `{"fInt32": 1, "fBool": true}`
+				}
+				require.NoError(t, protojson.Unmarshal([]byte(page), msg))
+				require.NoError(t, printer.Print(msg))
+			}
+			require.NoError(t, printer.Close())
+			require.Equal(t, tt.want, b.String())
+		})
+	}
+}
+
+func TestColumnsFlag(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "completion",
+			args: []string{"__complete", "Library", "ListBooks", "--columns", "name,"},
+			want: "name,name\nname,state\nname,createTime\nname,author\nname,author.displayName\nname,author.mentor\nname,tags\n:6\n",
+		},
+		{
+			name:    "without_table",
+			args:    []string{"Library", "ListBooks", "--columns", "name"},
+			wantErr: "--columns can only be used with --output table",
+		},
+		{
+			name:    "unknown",
+			args:    []string{"Library", "ListBooks", "-o", "table", "--columns", "title"},
+			wantErr: `invalid --columns: unknown column "title", expected one of name, state, createTime, author, author.displayName, author.mentor, tags`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var b bytes.Buffer
+			cmd.SetOut(&b)
+			cmd.SetArgs(tt.args)
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, listProto))))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, b.String())
+		})
+	}
+}
+
+func TestMarshalOptions(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		proto string
+		args  []string
+		opts  []CommandOption
+		want  string
+	}{
+		{
+			name:  "default",
+			proto: behaviorProto,
+			want:  `{"name":"string","billingAccount":{"accountId":"string"},"email":"string"}`,
+		},
+		{
+			name:  "emit_defaults",
+			proto: behaviorProto,
+			args:  []string{"--emit-defaults"},
+			want:  `{"name":"string","createTime":"","billingAccount":{"accountId":"string"},"email":"string"}`,
+		},
+		{
+			name:  "use_proto_names",
+			proto: behaviorProto,
+			args:  []string{"--use-proto-names"},
+			want:  `{"name":"string","billing_account":{"account_id":"string"},"email":"string"}`,
+		},
+		{
+			name:  "enums_as_ints",
+			proto: nestedProto,
+			args:  []string{"--enums-as-ints", "--jq", "[.state, .states]"},
+			want:  `[1,[1]]`,
+		},
+		{
+			name:  "option",
+			proto: behaviorProto,
+			opts:  []CommandOption{WithMarshalOptions(protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true})},
+			want:  `{"name":"string","create_time":"","billing_account":{"account_id":"string"},"email":"string"}`,
+		},
+		{
+			name:  "flag_overrides_option",
+			proto: behaviorProto,
+			args:  []string{"--use-proto-names=false"},
+			opts:  []CommandOption{WithMarshalOptions(protojson.MarshalOptions{UseProtoNames: true})},
+			want:  `{"name":"string","billingAccount":{"accountId":"string"},"email":"string"}`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stdout bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetArgs(append([]string{"Library", "Get", "--example", "-o", "json-compact"}, tt.args...))
+			require.NoError(t, BuildCommand(cmd, append(tt.opts, WithFileDescriptors(fileDescriptor(t, tt.proto)))...))
+			require.NoError(t, cmd.ExecuteContext(context.Background()))
+			require.Equal(t, tt.want+"\n", stdout.String())
+		})
+	}
+}
+
+const requiredProto = `
+name: "required.proto"
+package: "required"
+syntax: "proto2"
+message_type: {
+	name: "Request"
+	field: { name: "name" number: 1 type: TYPE_STRING label: LABEL_REQUIRED json_name: "name" }
+	field: { name: "count" number: 2 type: TYPE_INT32 label: LABEL_OPTIONAL json_name: "count" }
+}
+`
+
+func TestUnmarshalOptions(t *testing.T) {
+	t.Parallel()
+	input := fileDescriptor(t, requiredProto).Messages().Get(0)
+	tests := []struct {
+		name    string
+		json    string
+		options protojson.UnmarshalOptions
+		wantErr string
+	}{
+		{name: "valid", json: `{"name": "a"}`},
+		{name: "unknown", json: `{"name": "a", "other": 1}`, wantErr: `unknown field "other"`},
+		{name: "discard_unknown", json: `{"name": "a", "other": 1}`, options: protojson.UnmarshalOptions{DiscardUnknown: true}},
+		{name: "partial", json: `{"count": 1}`, wantErr: "required field required.Request.name not set"},
+		{name: "allow_partial", json: `{"count": 1}`, options: protojson.UnmarshalOptions{AllowPartial: true}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := grpcinternal.ParseMessage([]byte(tt.json), input, tt.options, false)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUnmarshalFlags(t *testing.T) {
+	t.Parallel()
+	port, err := example.ServeRand(context.Background(), func(server *grpc.Server) {
+		examplepb.RegisterFooAPIServer(server, &example.FooServer{})
+	})
+	require.NoError(t, err)
+	addr := fmt.Sprintf("http://localhost:%d", port)
+	tests := []struct {
+		name    string
+		args    []string
+		opts    []CommandOption
+		wantErr string
+	}{
+		{name: "unknown", wantErr: `unknown field "other"`},
+		{name: "discard_unknown", args: []string{"--discard-unknown"}},
+		{name: "option", opts: []CommandOption{WithUnmarshalOptions(protojson.UnmarshalOptions{DiscardUnknown: true})}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			args := append([]string{"grpctl", "--address=" + addr, "FooAPI", "Hello", `--json-data={"message": "blah", "other": 1}`}, tt.args...)
+			cmd := &cobra.Command{Use: "grpctl", SilenceErrors: true, SilenceUsage: true}
+			var b bytes.Buffer
+			cmd.SetOut(&b)
+			require.NoError(t, BuildCommand(cmd, append(tt.opts, WithArgs(args), WithReflection(args))...))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, b.String(), "Incoming Message: blah")
+		})
+	}
+}
+
+func TestColorOutput(t *testing.T) {
+	t.Parallel()
+	color := func(code string) func(string) string {
+		return func(s string) string { return "\x1b[" + code + "m" + s + "\x1b[0m" }
+	}
+	key, str, num, boolean := color("34;1"), color("32"), color("36"), color("33")
+	tests := []struct {
+		name    string
+		proto   string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "json",
+			args: []string{"--color=always", "-o", "json-compact", "--jq", "{fBool, fInt32, fBytes}"},
+			want: "{" + key(`"fBool"`) + ":" + boolean("true") + "," + key(`"fBytes"`) + ":" + str(`"ZkJ5dGVz"`) + "," + key(`"fInt32"`) + ":" + num("1") + "}\n",
+		},
+		{
+			name:  "message",
+			proto: behaviorProto,
+			args:  []string{"--color=always", "-o", "json-compact"},
+			want: "{" + key(`"name"`) + ":" + str(`"string"`) + "," + key(`"billingAccount"`) + ":{" + key(`"accountId"`) + ":" + str(`"string"`) + "}," +
+				key(`"email"`) + ":" + str(`"string"`) + "}\n",
+		},
+		{
+			name: "yaml",
+			args: []string{"--color=always", "-o", "yaml", "--jq", `{fBool, fInt64, text: "a\nb", list: [1.5, "x"]}`},
+			want: key("fBool") + ": " + boolean("true") + "\n" + key("fInt64") + ": " + str(`"1"`) + "\n" +
+				key("list") + ":\n  - " + num("1.5") + "\n  - " + str("x") + "\n" + key("text") + ": |-\n  " + str("a") + "\n  " + str("b") + "\n",
+		},
+		{
+			name: "never",
+			args: []string{"--color=never", "-o", "json-compact", "--jq", "{fBool}"},
+			want: "{\"fBool\":true}\n",
+		},
+		{
+			name: "auto_without_terminal",
+			args: []string{"-o", "json-compact", "--jq", "{fBool}"},
+			want: "{\"fBool\":true}\n",
+		},
+		{
+			name:    "unknown",
+			args:    []string{"--color=sometimes"},
+			wantErr: `invalid --color: unknown color mode "sometimes", expected one of auto, always, never`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{Use: "root", SilenceErrors: true, SilenceUsage: true}
+			var stdout bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetArgs(append([]string{"Library", "Get", "--example"}, tt.args...))
+			proto := tt.proto
+			if proto == "" {
+				proto = scalarsProto
+			}
+			require.NoError(t, BuildCommand(cmd, WithFileDescriptors(fileDescriptor(t, proto))))
+			err := cmd.ExecuteContext(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
+
+func TestPager(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{name: "fits", output: "a\nb\n", want: "a\nb\n"},
+		{name: "paged", output: "a\nb\nc\n", want: "paged:a\npaged:b\npaged:c\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cmd := &cobra.Command{}
+			cmd.SetContext(context.Background())
+			var stdout bytes.Buffer
+			p := &pager{out: &stdout, command: "sed -e s/^/paged:/", height: 3}
+			_, err := p.Write([]byte(tt.output))
+			require.NoError(t, err)
+			require.NoError(t, p.Close(cmd))
+			require.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
+
+// failingFooServer fails every call with err.
+type failingFooServer struct {
+	examplepb.UnimplementedFooAPIServer
+	err error
+}
+
+func (f failingFooServer) Hello(context.Context, *examplepb.ExampleRequest) (*examplepb.ExampleResponse, error) {
+	return nil, f.err
+}
+
+func TestErrorDetails(t *testing.T) {
+	t.Parallel()
+	st, err := status.New(codes.InvalidArgument, "invalid message").WithDetails(
+		&errdetails.ErrorInfo{Reason: "EMPTY_MESSAGE", Domain: "example.com", Metadata: map[string]string{"service": "FooAPI", "field": "message"}},
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "message", Description: "must not be empty"},
+			{Field: "name", Description: "must be set"},
+		}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{Subject: "project:1", Description: "daily limit exceeded"}}},
+		&errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{{Type: "TOS", Subject: "user:1", Description: "terms not accepted"}}},
+		&errdetails.Help{Links: []*errdetails.Help_Link{{Description: "docs", Url: "https://example.com"}}},
+	)
+	require.NoError(t, err)
+	port, err := example.ServeRand(context.Background(), func(server *grpc.Server) {
+		examplepb.RegisterFooAPIServer(server, failingFooServer{err: st.Err()})
+	})
+	require.NoError(t, err)
+	addr := fmt.Sprintf("http://localhost:%d", port)
+	text := `invalid_argument: invalid message
+  ErrorInfo: EMPTY_MESSAGE (example.com) field=message service=FooAPI
+  BadRequest: message: must not be empty
+  BadRequest: name: must be set
+  RetryInfo: retry after 1.5s
+  QuotaFailure: project:1: daily limit exceeded
+  PreconditionFailure: TOS user:1: terms not accepted
+  Help: links:{description:"docs" url:"https://example.com"}`
+	statusJSON := `{"code": 3, "message": "invalid message", "details": [
+				{"@type": "type.googleapis.com/google.rpc.ErrorInfo", "reason": "EMPTY_MESSAGE", "domain": "example.com", "metadata": {"field": "message", "service": "FooAPI"}},
+				{"@type": "type.googleapis.com/google.rpc.BadRequest", "fieldViolations": [
+					{"field": "message", "description": "must not be empty"}, {"field": "name", "description": "must be set"}]},
+				{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "1.500s"},
+				{"@type": "type.googleapis.com/google.rpc.QuotaFailure", "violations": [{"subject": "project:1", "description": "daily limit exceeded"}]},
+				{"@type": "type.googleapis.com/google.rpc.PreconditionFailure", "violations": [{"type": "TOS", "subject": "user:1", "description": "terms not accepted"}]},
+				{"@type": "type.googleapis.com/google.rpc.Help", "links": [{"description": "docs", "url": "https://example.com"}]}]}`
+	tests := []struct {
+		name       string
+		args       []string
+		wantErr    string
+		format     string
+		wantStderr string
+		json       bool
+	}{
+		{name: "default", wantErr: text},
+		{name: "table", args: []string{"-o", "table"}, wantErr: text},
+		{name: "binary", args: []string{"-o", "binary"}, wantErr: text},
+		{
+			name:       "json",
+			args:       []string{"-o", "json"},
+			wantErr:    text,
+			json:       true,
+			wantStderr: statusJSON,
+		},
+		{
+			name:       "json_option",
+			format:     "json",
+			wantErr:    text,
+			json:       true,
+			wantStderr: statusJSON,
+		},
+		{
+			name:       "json_compact",
+			args:       []string{"-o", "json-compact"},
+			wantErr:    text,
+			json:       true,
+			wantStderr: statusJSON,
+		},
+		{
+			name:    "yaml",
+			args:    []string{"-o", "yaml"},
+			wantErr: text,
+			wantStderr: `code: 3
+message: invalid message
+details:
+  - '@type': type.googleapis.com/google.rpc.ErrorInfo
+    reason: EMPTY_MESSAGE
+    domain: example.com
+    metadata:
+      field: message
+      service: FooAPI
+  - '@type': type.googleapis.com/google.rpc.BadRequest
+    fieldViolations:
+      - field: message
+        description: must not be empty
+      - field: name
+        description: must be set
+  - '@type': type.googleapis.com/google.rpc.RetryInfo
+    retryDelay: 1.500s
+  - '@type': type.googleapis.com/google.rpc.QuotaFailure
+    violations:
+      - subject: project:1
+        description: daily limit exceeded
+  - '@type': type.googleapis.com/google.rpc.PreconditionFailure
+    violations:
+      - type: TOS
+        subject: user:1
+        description: terms not accepted
+  - '@type': type.googleapis.com/google.rpc.Help
+    links:
+      - description: docs
+        url: https://example.com
+`,
+		},
+		{
+			name:    "prototext",
+			args:    []string{"-o", "prototext"},
+			wantErr: text,
+			wantStderr: `code: 3
+message: "invalid message"
+details: {
+  [type.googleapis.com/google.rpc.ErrorInfo]: {
+    reason: "EMPTY_MESSAGE"
+    domain: "example.com"
+    metadata: {
+      key: "field"
+      value: "message"
+    }
+    metadata: {
+      key: "service"
+      value: "FooAPI"
+    }
+  }
+}
+details: {
+  [type.googleapis.com/google.rpc.BadRequest]: {
+    field_violations: {
+      field: "message"
+      description: "must not be empty"
+    }
+    field_violations: {
+      field: "name"
+      description: "must be set"
+    }
+  }
+}
+details: {
+  [type.googleapis.com/google.rpc.RetryInfo]: {
+    retry_delay: {
+      seconds: 1
+      nanos: 500000000
+    }
+  }
+}
+details: {
+  [type.googleapis.com/google.rpc.QuotaFailure]: {
+    violations: {
+      subject: "project:1"
+      description: "daily limit exceeded"
+    }
+  }
+}
+details: {
+  [type.googleapis.com/google.rpc.PreconditionFailure]: {
+    violations: {
+      type: "TOS"
+      subject: "user:1"
+      description: "terms not accepted"
+    }
+  }
+}
+details: {
+  [type.googleapis.com/google.rpc.Help]: {
+    links: {
+      description: "docs"
+      url: "https://example.com"
+    }
+  }
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			args := append([]string{"grpctl", "--address=" + addr, "FooAPI", "Hello", "--message=blah"}, tt.args...)
+			cmd := &cobra.Command{Use: "grpctl"}
+			var stdout, stderr bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			opts := []CommandOption{WithArgs(args)}
+			if tt.format != "" {
+				opts = append(opts, WithOutputFormat(tt.format))
+			}
+			require.NoError(t, BuildCommand(cmd, append(opts, WithReflection(args))...))
+			err := cmd.ExecuteContext(context.Background())
+			require.Error(t, err)
+			// prototext randomly adds spaces to its output.
+			spaces := regexp.MustCompile(`[ \x{00a0}]+`)
+			require.Equal(t, spaces.ReplaceAllString(tt.wantErr, " "), spaces.ReplaceAllString(err.Error(), " "))
+			var statusErr *grpcinternal.StatusError
+			require.ErrorAs(t, err, &statusErr)
+			require.Empty(t, stdout.String())
+			if tt.wantStderr == "" {
+				require.Equal(t, spaces.ReplaceAllString("Error: "+tt.wantErr+"\n", " "), spaces.ReplaceAllString(stderr.String(), " "))
+				return
+			}
+			if tt.json {
+				require.JSONEq(t, tt.wantStderr, stderr.String())
+				return
+			}
+			require.Equal(t, spaces.ReplaceAllString(tt.wantStderr, " "), spaces.ReplaceAllString(stderr.String(), " "))
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+	port, err := example.ServeRand(context.Background(), func(server *grpc.Server) {
+		examplepb.RegisterFooAPIServer(server, failingFooServer{err: status.Error(codes.NotFound, "no such message")})
+	})
+	require.NoError(t, err)
+	addr := fmt.Sprintf("http://localhost:%d", port)
+	tests := []struct {
+		name string
+		args []string
+		err  error
+		want int
+	}{
+		{name: "nil", want: 0},
+		{name: "local", err: errors.New("invalid request"), want: 1},
+		{name: "grpc_status", err: fmt.Errorf("reflection: %w", status.Error(codes.Unavailable, "connection refused")), want: 114},
+		{name: "call", args: []string{"FooAPI", "Hello", "--message=blah"}, want: 105},
+		{name: "call_json", args: []string{"FooAPI", "Hello", "--message=blah", "-o", "json"}, want: 105},
+		{name: "unknown_flag", args: []string{"FooAPI", "Hello", "--unknown"}, want: 1},
+		{name: "invalid_request", args: []string{"FooAPI", "Hello", "--json-data={"}, want: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.err
+			if tt.args != nil {
+				args := append([]string{"grpctl", "--address=" + addr}, tt.args...)
+				cmd := &cobra.Command{Use: "grpctl", SilenceErrors: true, SilenceUsage: true}
+				cmd.SetOut(io.Discard)
+				cmd.SetErr(io.Discard)
+				require.NoError(t, BuildCommand(cmd, WithArgs(args), WithReflection(args)))
+				err = cmd.ExecuteContext(context.Background())
+			}
+			require.Equal(t, tt.want, ExitCode(err))
+		})
+	}
+}
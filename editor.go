@@ -0,0 +1,119 @@
+package grpctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/joshcarp/grpctl/internal/descriptors"
+	"github.com/joshcarp/grpctl/internal/grpc"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// editorCommand returns the command that opens files for --edit: the one given by WithEditor, $VISUAL, $EDITOR or vi.
+func editorCommand(s *settings) string {
+	for _, editor := range []string{s.editor, os.Getenv("VISUAL"), os.Getenv("EDITOR")} {
+		if strings.TrimSpace(editor) != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// editRequest writes request, or the template of the method's input if it is empty, to a temporary file and opens it
+// in an editor. The edited request is checked like a request from --json-data, and the editor is reopened if that fails.
+// It returns false if the file is emptied, which cancels the request.
+func editRequest(
+	cmd *cobra.Command,
+	method protoreflect.MethodDescriptor,
+	flags *flagBuilder,
+	request map[string]interface{},
+	unmarshal protojson.UnmarshalOptions,
+	validateRequest bool,
+) (map[string]interface{}, bool, error) {
+	if len(request) == 0 {
+		request, _ = descriptors.MakeJSONTemplate(method.Input())
+	}
+	b, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return nil, false, err
+	}
+	f, err := os.CreateTemp("", fmt.Sprintf("grpctl-%s-*.json", method.Name()))
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return nil, false, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, false, err
+	}
+	p := newPrompter(cmd.InOrStdin(), cmd.ErrOrStderr(), flags.settings)
+	for {
+		if err := runEditor(cmd, editorCommand(flags.settings), f.Name()); err != nil {
+			return nil, false, err
+		}
+		b, err := os.ReadFile(f.Name())
+		if err != nil {
+			return nil, false, err
+		}
+		if len(strings.TrimSpace(string(b))) == 0 {
+			fmt.Fprintln(cmd.ErrOrStderr(), "the request is empty, not sending it")
+			return nil, false, nil
+		}
+		request, err := parseEdited(method, flags, b, unmarshal, validateRequest)
+		if err == nil {
+			return request, true, nil
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Error: %v\n", err)
+		again, confirmErr := p.confirm("Edit the request again?", true)
+		if confirmErr != nil || !again {
+			return nil, false, err
+		}
+	}
+}
+
+// runEditor opens path with editor, which can include arguments, eg `code --wait`.
+func runEditor(cmd *cobra.Command, editor, path string) error {
+	args := strings.Fields(editor)
+	c := exec.CommandContext(cmd.Context(), args[0], append(args[1:], path)...) //nolint:gosec // the editor is chosen by the user
+	if stdin, ok := cmd.InOrStdin().(*os.File); ok {
+		// Other readers are not given to the editor, which would otherwise copy all of them before it exits.
+		c.Stdin = stdin
+	}
+	c.Stdout = cmd.OutOrStdout()
+	c.Stderr = cmd.ErrOrStderr()
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+func parseEdited(
+	method protoreflect.MethodDescriptor, flags *flagBuilder, b []byte, unmarshal protojson.UnmarshalOptions, validateRequest bool,
+) (map[string]interface{}, error) {
+	request, err := descriptors.UnmarshalJSON(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := descriptors.ExpandMessageBytesFiles(method.Input(), request); err != nil {
+		return nil, err
+	}
+	if err := checkRequired(flags, request); err != nil {
+		return nil, err
+	}
+	b, err = json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := grpc.ParseMessage(b, method.Input(), unmarshal, validateRequest); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
@@ -0,0 +1,37 @@
+package grpctl
+
+import (
+	"errors"
+
+	"github.com/bufbuild/connect-go"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// exitError is the exit code of errors that happen before a call is made, such as invalid flags or requests.
+	exitError = 1
+	// exitStatus is added to the gRPC status code of a failed call. The resulting 100 to 116 don't overlap with the
+	// codes of sysexits.h (64 to 78) or those that shells use for failed commands and signals (126 and up).
+	exitStatus = 100
+)
+
+// ExitCode returns the exit code of a command that returned err, for use with os.Exit:
+//
+//	0        no error
+//	1        a local error, such as a usage error or a request that can't be parsed
+//	100 + c  a call failed with the gRPC status code c, eg 105 for NOT_FOUND and 114 for UNAVAILABLE
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return exitStatus + int(connectErr.Code())
+	}
+	// Reflection calls are made with grpc-go.
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		return exitStatus + int(grpcErr.GRPCStatus().Code())
+	}
+	return exitError
+}
@@ -0,0 +1,287 @@
+package grpctl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joshcarp/grpctl/internal/descriptors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxFlagDepth is the number of message levels that are flattened into dotted flags.
+// Message fields below it, or that refer back to a message on their own path, are set as JSON through a single flag.
+const maxFlagDepth = 4
+
+// flagBuilder collects the flags of a method command from its input message.
+type flagBuilder struct {
+	settings *settings
+	dataMap  descriptors.DataMap
+	// aliases maps every alternative flag name to the flag name it is accepted as, see normalizeFlags.
+	aliases map[string]string
+	// required holds the flags of fields annotated as REQUIRED.
+	required map[string]requiredField
+	// exclusive holds the pairs of flags that belong to different members of the same oneof.
+	exclusive [][]string
+}
+
+// requiredField is a field annotated as REQUIRED. It is required even if the message it is in isn't set when
+// always is set, which is the case when all of the messages on its path are required as well.
+type requiredField struct {
+	// path is the field and the message fields it is in.
+	path   []protoreflect.FieldDescriptor
+	always bool
+}
+
+func newFlagBuilder(s *settings) *flagBuilder {
+	return &flagBuilder{settings: s, dataMap: make(descriptors.DataMap), aliases: map[string]string{}, required: map[string]requiredField{}}
+}
+
+// addMessage adds a flag for every field of message, flattening singular message fields into dotted flags
+// such as `--billingAccount.displayName`. required is set if message itself must be set in the request.
+// It returns the names of the flags it added.
+func (b *flagBuilder) addMessage(
+	message protoreflect.MessageDescriptor, flagPrefix string, parents []protoreflect.FieldDescriptor, seen []protoreflect.MessageDescriptor, required bool,
+) []string {
+	var added []string
+	oneofs := map[protoreflect.FullName][][]string{}
+	for i := 0; i < message.Fields().Len(); i++ {
+		field := message.Fields().Get(i)
+		if b.settings.hideDeprecated && descriptors.Deprecated(field) {
+			continue
+		}
+		if descriptors.HasFieldBehavior(field, annotations.FieldBehavior_OUTPUT_ONLY) {
+			continue
+		}
+		fieldRequired := descriptors.HasFieldBehavior(field, annotations.FieldBehavior_REQUIRED)
+		flagName := joinFlag(flagPrefix, b.settings.naming.flag(field))
+		for _, alias := range flagAliases(field) {
+			if alias := joinFlag(flagPrefix, alias); alias != flagName {
+				b.aliases[alias] = flagName
+			}
+		}
+		fields := append(append([]protoreflect.FieldDescriptor{}, parents...), field)
+		names := []string{flagName}
+		if flatten(field) && len(fields) < maxFlagDepth && !containsMessage(seen, field.Message()) {
+			names = b.addMessage(field.Message(), flagName, fields, append(append([]protoreflect.MessageDescriptor{}, seen...), field.Message()), required && fieldRequired)
+		} else {
+			b.dataMap[flagName] = b.newValue(field, jsonPath(fields), fieldRequired)
+		}
+		if fieldRequired {
+			b.required[flagName] = requiredField{path: fields, always: required}
+		}
+		if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			oneofs[oneof.FullName()] = append(oneofs[oneof.FullName()], names)
+		}
+		added = append(added, names...)
+	}
+	for i := 0; i < message.Oneofs().Len(); i++ {
+		members := oneofs[message.Oneofs().Get(i).FullName()]
+		for m, member := range members {
+			for _, other := range members[m+1:] {
+				for _, a := range member {
+					for _, o := range other {
+						b.exclusive = append(b.exclusive, []string{a, o})
+					}
+				}
+			}
+		}
+	}
+	return added
+}
+
+// newValue returns the value of the flag of a field at path in the request.
+func (b *flagBuilder) newValue(field protoreflect.FieldDescriptor, path []string, required bool) *descriptors.DataValue {
+	val := &descriptors.DataValue{
+		Path:       path,
+		Kind:       field.Kind(),
+		Value:      field.Default().Interface(),
+		Proto:      true,
+		Empty:      true,
+		Usage:      descriptors.Description(field),
+		Deprecated: deprecated(field),
+	}
+	if field.Kind() == protoreflect.EnumKind {
+		val.Enum = field.Enum()
+	}
+	if field.Kind() == protoreflect.MessageKind {
+		val.Message = field.Message()
+	}
+	if field.IsMap() {
+		val.Map = true
+		val.MapKey = field.MapKey().Kind()
+		val.Kind = field.MapValue().Kind()
+		val.Enum = field.MapValue().Enum()
+		val.Message = field.MapValue().Message()
+		val.Value = map[string]interface{}{}
+		val.Usage = strings.TrimSpace(val.Usage + " (repeatable key=value)")
+	}
+	if field.IsList() {
+		val.Repeated = true
+		val.Value = []interface{}{}
+		val.Usage = strings.TrimSpace(val.Usage + " (repeatable)")
+	}
+	if required {
+		val.Usage = strings.TrimSpace(val.Usage + " (required)")
+	}
+	return val
+}
+
+// removeShadowed removes the flags of fields that have the name of a flag in fs, such as a field named output, and
+// returns their names, sorted. These fields can still be set with --json-data.
+func (b *flagBuilder) removeShadowed(fs *pflag.FlagSet) []string {
+	var shadowed []string
+	for name := range b.dataMap {
+		if fs.Lookup(name) != nil {
+			shadowed = append(shadowed, name)
+		}
+	}
+	sort.Strings(shadowed)
+	for _, name := range shadowed {
+		delete(b.dataMap, name)
+		delete(b.required, name)
+		for alias, flag := range b.aliases {
+			if flag == name {
+				delete(b.aliases, alias)
+			}
+		}
+		exclusive := b.exclusive[:0]
+		for _, group := range b.exclusive {
+			if !containsString(group, name) {
+				exclusive = append(exclusive, group)
+			}
+		}
+		b.exclusive = exclusive
+	}
+	return shadowed
+}
+
+// missingRequired returns the required flags whose fields aren't set in request, sorted by name.
+// A required field of an optional message is only missing if the message is set.
+func (b *flagBuilder) missingRequired(request map[string]interface{}) []string {
+	var missing []string
+	for name, field := range b.required {
+		if !field.always && lookupField(request, field.path[:len(field.path)-1]) == nil {
+			continue
+		}
+		if isEmpty(lookupField(request, field.path)) {
+			missing = append(missing, name)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func isEmpty(val interface{}) bool {
+	switch val := val.(type) {
+	case nil:
+		return true
+	case []interface{}:
+		return len(val) == 0
+	case map[string]interface{}:
+		return len(val) == 0
+	}
+	return false
+}
+
+// flatten reports whether the fields of a field's message should be added as dotted flags.
+// Well known types are left as a single flag, as they have their own JSON representation.
+func flatten(field protoreflect.FieldDescriptor) bool {
+	return field.Kind() == protoreflect.MessageKind &&
+		!field.IsList() && !field.IsMap() &&
+		field.Message().ParentFile().Package() != "google.protobuf"
+}
+
+func containsMessage(messages []protoreflect.MessageDescriptor, message protoreflect.MessageDescriptor) bool {
+	for _, m := range messages {
+		if m.FullName() == message.FullName() {
+			return true
+		}
+	}
+	return false
+}
+
+func joinFlag(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// jsonPath returns the JSON names of a path of fields.
+func jsonPath(fields []protoreflect.FieldDescriptor) []string {
+	path := make([]string, 0, len(fields))
+	for _, field := range fields {
+		path = append(path, field.JSONName())
+	}
+	return path
+}
+
+// lookupField returns the value of a path of fields in a JSON request, which may use JSON or proto field names.
+func lookupField(m map[string]interface{}, fields []protoreflect.FieldDescriptor) interface{} {
+	var val interface{} = m
+	for _, field := range fields {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		val, ok = obj[field.JSONName()]
+		if !ok {
+			val = obj[string(field.Name())]
+		}
+	}
+	return val
+}
+
+// lookupPath returns the value at path in a nested JSON map, or nil if there is none.
+func lookupPath(m map[string]interface{}, path []string) interface{} {
+	var val interface{} = m
+	for _, key := range path {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		val = obj[key]
+	}
+	return val
+}
+
+// flagCompletion returns the completion function of a field's flag.
+// Enum flags complete to their value names, other flags to the value used in the request template, or its first
+// element or entry for repeated and map fields.
+func flagCompletion(val *descriptors.DataValue, template map[string]interface{}) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	if val.Enum != nil && !val.Map {
+		values := val.Enum.Values()
+		completions := make([]string, 0, values.Len())
+		for i := 0; i < values.Len(); i++ {
+			value := values.Get(i)
+			completion := string(value.Name())
+			if summary := descriptors.Summary(value); summary != "" {
+				completion += "\t" + summary
+			}
+			completions = append(completions, completion)
+		}
+		return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return completions, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
+	completion := fmt.Sprintf("%v", lookupPath(template, val.Path))
+	switch example := lookupPath(template, val.Path).(type) {
+	case []interface{}:
+		if len(example) > 0 {
+			completion = fmt.Sprintf("%v", example[0])
+		}
+	case map[string]interface{}:
+		if val.Map {
+			for k, v := range example {
+				completion = fmt.Sprintf("%s=%v", k, v)
+			}
+		}
+	}
+	return func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return []string{completion}, cobra.ShellCompDirectiveDefault
+	}
+}
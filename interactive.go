@@ -0,0 +1,374 @@
+package grpctl
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/joshcarp/grpctl/internal/descriptors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// prompter builds a request by asking for the value of every field of a message.
+// Values are parsed like the values of flags, and an empty answer keeps the field's current value or leaves it unset.
+type prompter struct {
+	in    *bufio.Reader
+	out   io.Writer
+	flags *flagBuilder
+}
+
+func newPrompter(in io.Reader, out io.Writer, s *settings) *prompter {
+	return &prompter{in: bufio.NewReader(in), out: out, flags: newFlagBuilder(s)}
+}
+
+// buildRequest prompts for the fields of md, starting from the values in request, then prints the request as JSON
+// and asks whether to send it.
+func (p *prompter) buildRequest(md protoreflect.MessageDescriptor, request map[string]interface{}) (map[string]interface{}, bool, error) {
+	request, err := p.message(md, "", request)
+	if err != nil {
+		return nil, false, err
+	}
+	b, err := json.MarshalIndent(request, "", " ")
+	if err != nil {
+		return nil, false, err
+	}
+	fmt.Fprintf(p.out, "%s\n", b)
+	send, err := p.confirm("Send request?", true)
+	return request, send, err
+}
+
+func (p *prompter) message(md protoreflect.MessageDescriptor, prefix string, current map[string]interface{}) (map[string]interface{}, error) {
+	msg := map[string]interface{}{}
+	for k, v := range current {
+		msg[k] = v
+	}
+	chosen := map[protoreflect.FullName]protoreflect.FieldDescriptor{}
+	for i := 0; i < md.Fields().Len(); i++ {
+		field := md.Fields().Get(i)
+		if p.flags.settings.hideDeprecated && descriptors.Deprecated(field) {
+			continue
+		}
+		if descriptors.HasFieldBehavior(field, annotations.FieldBehavior_OUTPUT_ONLY) {
+			continue
+		}
+		if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			if _, ok := chosen[oneof.FullName()]; !ok {
+				member, err := p.oneof(oneof, prefix, msg)
+				if err != nil {
+					return nil, err
+				}
+				chosen[oneof.FullName()] = member
+			}
+			if chosen[oneof.FullName()] != field {
+				continue
+			}
+		}
+		if err := p.field(field, prefix, msg); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// oneof asks which member of a oneof to set, removing the other members from msg.
+func (p *prompter) oneof(oneof protoreflect.OneofDescriptor, prefix string, msg map[string]interface{}) (protoreflect.FieldDescriptor, error) {
+	fields := oneof.Fields()
+	var current protoreflect.FieldDescriptor
+	names := make([]string, 0, fields.Len())
+	for i := 0; i < fields.Len(); i++ {
+		names = append(names, p.name(fields.Get(i)))
+		if _, ok := lookupValue(msg, fields.Get(i)); ok {
+			current = fields.Get(i)
+		}
+	}
+	p.comment(oneof)
+	for {
+		def := ""
+		if current != nil {
+			def = p.name(current)
+		}
+		answer, err := p.ask(fmt.Sprintf("%s (one of %s)", joinFlag(prefix, string(oneof.Name())), strings.Join(names, ", ")), def)
+		if err != nil {
+			return nil, err
+		}
+		if answer == "" {
+			return nil, nil
+		}
+		for i := 0; i < fields.Len(); i++ {
+			if field := fields.Get(i); answer == p.name(field) || answer == string(field.Name()) {
+				for j := 0; j < fields.Len(); j++ {
+					if j != i {
+						deleteValue(msg, fields.Get(j))
+					}
+				}
+				return field, nil
+			}
+		}
+		fmt.Fprintf(p.out, "expected one of %s\n", strings.Join(names, ", "))
+	}
+}
+
+func (p *prompter) field(field protoreflect.FieldDescriptor, prefix string, msg map[string]interface{}) error {
+	name := joinFlag(prefix, p.name(field))
+	current, hasCurrent := lookupValue(msg, field)
+	p.comment(field)
+	if field.Kind() == protoreflect.EnumKind && !field.IsMap() {
+		p.enumValues(field.Enum())
+	}
+	switch {
+	case field.IsMap():
+		entries, _ := current.(map[string]interface{})
+		entries, err := p.mapEntries(field, name, entries)
+		if err != nil {
+			return err
+		}
+		setValue(msg, field, entries)
+		return nil
+	case field.IsList():
+		elems, _ := current.([]interface{})
+		elems, err := p.list(field, name, elems)
+		if err != nil {
+			return err
+		}
+		setValue(msg, field, elems)
+		return nil
+	case flatten(field):
+		nested, _ := current.(map[string]interface{})
+		set, err := p.confirm(fmt.Sprintf("Set %s (%s)?", name, field.Message().FullName()), hasCurrent)
+		if err != nil || !set {
+			return err
+		}
+		nested, err = p.message(field.Message(), name, nested)
+		if err != nil {
+			return err
+		}
+		setValue(msg, field, nested)
+		return nil
+	}
+	note := ""
+	required := descriptors.HasFieldBehavior(field, annotations.FieldBehavior_REQUIRED)
+	if required {
+		note = "required"
+	}
+	for {
+		def := ""
+		if hasCurrent {
+			def = formatValue(current)
+		}
+		answer, err := p.ask(p.prompt(field, name, note), def)
+		if err != nil {
+			return err
+		}
+		if answer == "" {
+			if required && !hasCurrent {
+				fmt.Fprintf(p.out, "%s is required\n", name)
+				continue
+			}
+			return nil
+		}
+		val, err := p.parse(field, answer)
+		if err != nil {
+			fmt.Fprintf(p.out, "invalid value: %v\n", err)
+			continue
+		}
+		setValue(msg, field, val)
+		return nil
+	}
+}
+
+// list asks for the elements of a repeated field until an empty answer, starting with the elements it already has.
+func (p *prompter) list(field protoreflect.FieldDescriptor, name string, elems []interface{}) ([]interface{}, error) {
+	if len(elems) > 0 {
+		keep, err := p.confirm(fmt.Sprintf("Keep %d element(s) of %s?", len(elems), name), true)
+		if err != nil {
+			return nil, err
+		}
+		if !keep {
+			elems = nil
+		}
+	}
+	for {
+		elemName := fmt.Sprintf("%s[%d]", name, len(elems))
+		if field.Message() != nil && !descriptors.IsWellKnown(field.Message()) {
+			add, err := p.confirm(fmt.Sprintf("Add %s (%s)?", elemName, field.Message().FullName()), false)
+			if err != nil || !add {
+				return elems, err
+			}
+			elem, err := p.message(field.Message(), elemName, nil)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+			continue
+		}
+		answer, err := p.ask(p.prompt(field, elemName, "empty to finish"), "")
+		if err != nil || answer == "" {
+			return elems, err
+		}
+		val, err := p.parse(field, answer)
+		if err != nil {
+			fmt.Fprintf(p.out, "invalid value: %v\n", err)
+			continue
+		}
+		list, _ := val.([]interface{})
+		elems = append(elems, list...)
+	}
+}
+
+// mapEntries asks for the entries of a map field as key=value until an empty answer.
+func (p *prompter) mapEntries(field protoreflect.FieldDescriptor, name string, entries map[string]interface{}) (map[string]interface{}, error) {
+	if entries == nil {
+		entries = map[string]interface{}{}
+	}
+	for {
+		answer, err := p.ask(p.prompt(field, name, "key=value, empty to finish"), "")
+		if err != nil || answer == "" {
+			return entries, err
+		}
+		val, err := p.parse(field, answer)
+		if err != nil {
+			fmt.Fprintf(p.out, "invalid value: %v\n", err)
+			continue
+		}
+		entry, _ := val.(map[string]interface{})
+		for k, v := range entry {
+			entries[k] = v
+		}
+	}
+}
+
+// parse parses an answer like the value of the field's flag. The elements of repeated fields are returned as a list,
+// as they can be given comma separated, and map entries as a map.
+func (p *prompter) parse(field protoreflect.FieldDescriptor, answer string) (interface{}, error) {
+	val := p.flags.newValue(field, nil, false)
+	if err := val.Set(answer); err != nil {
+		return nil, err
+	}
+	return val.Value, nil
+}
+
+// prompt returns the prompt of a field with its flag type and a note, eg `count (int32, required)`.
+func (p *prompter) prompt(field protoreflect.FieldDescriptor, name, note string) string {
+	typ := p.flags.newValue(field, nil, false).Type()
+	if field.IsList() {
+		typ = strings.TrimSuffix(typ, "Slice")
+	}
+	if note != "" {
+		typ += ", " + note
+	}
+	return fmt.Sprintf("%s (%s)", name, typ)
+}
+
+func (p *prompter) name(field protoreflect.FieldDescriptor) string {
+	return p.flags.settings.naming.flag(field)
+}
+
+// comment prints the comments of a descriptor, and whether it is deprecated.
+func (p *prompter) comment(descriptor protoreflect.Descriptor) {
+	if description := descriptors.Description(descriptor); description != "" {
+		fmt.Fprintf(p.out, "# %s\n", description)
+	}
+	if msg := deprecated(descriptor); msg != "" {
+		fmt.Fprintf(p.out, "# %s\n", msg)
+	}
+}
+
+func (p *prompter) enumValues(enum protoreflect.EnumDescriptor) {
+	for i := 0; i < enum.Values().Len(); i++ {
+		value := enum.Values().Get(i)
+		line := fmt.Sprintf("  %d: %s", value.Number(), value.Name())
+		if summary := descriptors.Summary(value); summary != "" {
+			line += " - " + summary
+		}
+		fmt.Fprintln(p.out, line)
+	}
+}
+
+// ask prints a prompt with a default answer and reads a line, returning the default for an empty line.
+func (p *prompter) ask(prompt, def string) (string, error) {
+	if def != "" {
+		prompt += " [" + def + "]"
+	}
+	fmt.Fprintf(p.out, "%s: ", prompt)
+	line, err := p.in.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		fmt.Fprintln(p.out)
+		return "", fmt.Errorf("interactive input ended: %w", io.ErrUnexpectedEOF)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+func (p *prompter) confirm(prompt string, def bool) (bool, error) {
+	choices := "y/N"
+	if def {
+		choices = "Y/n"
+	}
+	for {
+		answer, err := p.ask(fmt.Sprintf("%s [%s]", prompt, choices), "")
+		if err != nil {
+			return false, err
+		}
+		if answer == "" {
+			return def, nil
+		}
+		if yes, err := strconv.ParseBool(answer); err == nil {
+			return yes, nil
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// setValue sets the value of a field in a JSON message, replacing it if it is set by its proto name.
+func setValue(msg map[string]interface{}, field protoreflect.FieldDescriptor, val interface{}) {
+	deleteValue(msg, field)
+	if !isEmpty(val) {
+		msg[field.JSONName()] = val
+	}
+}
+
+// lookupValue returns the value of a field in a JSON message, which may use its JSON or proto name.
+func lookupValue(msg map[string]interface{}, field protoreflect.FieldDescriptor) (interface{}, bool) {
+	if val, ok := msg[field.JSONName()]; ok {
+		return val, true
+	}
+	val, ok := msg[string(field.Name())]
+	return val, ok
+}
+
+func deleteValue(msg map[string]interface{}, field protoreflect.FieldDescriptor) {
+	delete(msg, field.JSONName())
+	delete(msg, string(field.Name()))
+}
+
+// formatValue formats a value of a JSON message as it would be entered.
+func formatValue(val interface{}) string {
+	switch val := val.(type) {
+	case string:
+		return val
+	case json.RawMessage:
+		return string(val)
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	}
+	return fmt.Sprint(val)
+}
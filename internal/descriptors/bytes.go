@@ -0,0 +1,114 @@
+package descriptors
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ParseBytes parses the value of a bytes flag:
+//   - `@path` reads the bytes from a file
+//   - `-` reads the bytes from stdin
+//   - `base64:...` and `hex:...` decode the bytes
+//   - `raw:...` is used as is
+//   - anything else is decoded as base64, as protojson does.
+func ParseBytes(val string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case val == "-", strings.HasPrefix(val, "@"):
+		return ReadData(val, stdin)
+	case strings.HasPrefix(val, "base64:"):
+		return decodeBase64(strings.TrimPrefix(val, "base64:"))
+	case strings.HasPrefix(val, "hex:"):
+		return hex.DecodeString(strings.TrimPrefix(val, "hex:"))
+	case strings.HasPrefix(val, "raw:"):
+		return []byte(strings.TrimPrefix(val, "raw:")), nil
+	}
+	b, err := decodeBase64(val)
+	if err != nil {
+		return nil, fmt.Errorf("%w, use raw:%s to send the text as it is", err, val)
+	}
+	return b, nil
+}
+
+// decodeBase64 decodes both padded and unpadded, standard and URL safe encodings, which protojson all accepts.
+func decodeBase64(encoded string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	}
+	return b, err
+}
+
+// ExpandMessageBytesFiles replaces the values of bytes fields in an unmarshalled JSON message that are in the form
+// `@path` with the base64 encoded contents of the file. The message is modified in place.
+func ExpandMessageBytesFiles(md protoreflect.MessageDescriptor, msg map[string]interface{}) error {
+	return expandBytesFiles(md, msg)
+}
+
+func expandBytesFiles(md protoreflect.MessageDescriptor, msg interface{}) error {
+	obj, ok := msg.(map[string]interface{})
+	if !ok || IsWellKnown(md) {
+		return nil
+	}
+	for key, val := range obj {
+		field := fieldByName(md, key)
+		if field == nil {
+			continue
+		}
+		if field.IsMap() {
+			field = field.MapValue()
+			vals, _ := val.(map[string]interface{})
+			for k, v := range vals {
+				expanded, err := expandBytesValue(field, v)
+				if err != nil {
+					return err
+				}
+				vals[k] = expanded
+			}
+			continue
+		}
+		if field.IsList() {
+			vals, _ := val.([]interface{})
+			for i, v := range vals {
+				expanded, err := expandBytesValue(field, v)
+				if err != nil {
+					return err
+				}
+				vals[i] = expanded
+			}
+			continue
+		}
+		expanded, err := expandBytesValue(field, val)
+		if err != nil {
+			return err
+		}
+		obj[key] = expanded
+	}
+	return nil
+}
+
+func expandBytesValue(field protoreflect.FieldDescriptor, val interface{}) (interface{}, error) {
+	switch field.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if field.Message().FullName() != "google.protobuf.BytesValue" {
+			return val, expandBytesFiles(field.Message(), val)
+		}
+	case protoreflect.BytesKind:
+	default:
+		return val, nil
+	}
+	path, ok := val.(string)
+	if !ok || !strings.HasPrefix(path, "@") {
+		return val, nil
+	}
+	b, err := os.ReadFile(strings.TrimPrefix(path, "@"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field.FullName(), err)
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
@@ -2,7 +2,10 @@ package descriptors
 
 import (
 	"fmt"
+	"strings"
 
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
@@ -10,8 +13,57 @@ func FullMethod(c protoreflect.MethodDescriptor) string {
 	return fmt.Sprintf("/%s/%s", c.Parent().FullName(), c.Name())
 }
 
-func Command(descriptor protoreflect.Descriptor) string {
-	return string(descriptor.Name())
+// Deprecated reports whether a descriptor has the deprecated option set.
+func Deprecated(descriptor protoreflect.Descriptor) bool {
+	opts, ok := descriptor.Options().(interface{ GetDeprecated() bool })
+	return ok && opts.GetDeprecated()
+}
+
+// HasFieldBehavior reports whether a field is annotated with the `google.api.field_behavior` behavior.
+func HasFieldBehavior(field protoreflect.FieldDescriptor, behavior annotations.FieldBehavior) bool {
+	behaviors, _ := proto.GetExtension(field.Options(), annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+	for _, b := range behaviors {
+		if b == behavior {
+			return true
+		}
+	}
+	return false
+}
+
+// Comments returns the leading and trailing comments of a descriptor.
+// Both are empty if the source info has been stripped from the descriptor's file.
+func Comments(descriptor protoreflect.Descriptor) (string, string) {
+	file := descriptor.ParentFile()
+	if file == nil {
+		return "", ""
+	}
+	loc := file.SourceLocations().ByDescriptor(descriptor)
+	return cleanComment(loc.LeadingComments), cleanComment(loc.TrailingComments)
+}
+
+// Summary returns the first line of the leading comment of a descriptor, falling back to the trailing comment.
+func Summary(descriptor protoreflect.Descriptor) string {
+	leading, trailing := Comments(descriptor)
+	comment := leading
+	if comment == "" {
+		comment = trailing
+	}
+	first, _, _ := strings.Cut(comment, "\n")
+	return strings.TrimSpace(first)
+}
+
+// Description returns the leading and trailing comments of a descriptor joined into a single paragraph.
+func Description(descriptor protoreflect.Descriptor) string {
+	leading, trailing := Comments(descriptor)
+	return strings.Join(strings.Fields(leading+" "+trailing), " ")
+}
+
+func cleanComment(comment string) string {
+	lines := strings.Split(strings.TrimRight(comment, "\n "), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
 }
 
 func ServicesFromFileDescriptor(c protoreflect.FileDescriptor) []protoreflect.ServiceDescriptor {
@@ -0,0 +1,170 @@
+package descriptors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadData reads a request given as a flag value: `@path` reads it from a file, `-` from stdin,
+// anything else is the request itself.
+func ReadData(val string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case val == "-":
+		if stdin == nil {
+			return nil, fmt.Errorf("stdin is not available")
+		}
+		return io.ReadAll(stdin)
+	case strings.HasPrefix(val, "@"):
+		return os.ReadFile(strings.TrimPrefix(val, "@"))
+	}
+	return []byte(val), nil
+}
+
+// UnmarshalJSON unmarshals a JSON object, keeping numbers as json.Number so that 64 bit integers keep their precision.
+func UnmarshalJSON(b []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	m := map[string]interface{}{}
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// JSONMessages unmarshals a JSON object, or an array of objects, into messages.
+func JSONMessages(b []byte) ([]map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var val interface{}
+	if err := decoder.Decode(&val); err != nil {
+		return nil, err
+	}
+	switch val := val.(type) {
+	case map[string]interface{}:
+		return []map[string]interface{}{val}, nil
+	case []interface{}:
+		messages := make([]map[string]interface{}, 0, len(val))
+		for i, elem := range val {
+			msg, ok := elem.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("element %d is not a JSON object", i)
+			}
+			messages = append(messages, msg)
+		}
+		return messages, nil
+	}
+	return nil, fmt.Errorf("expected a JSON object or an array of objects")
+}
+
+// YAMLMessages decodes every document of a YAML stream into a message with the values JSON would have.
+// Timestamps are formatted as RFC3339 and binary values are kept in base64, as protojson expects them.
+func YAMLMessages(b []byte) ([]map[string]interface{}, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	var messages []map[string]interface{}
+	for i := 1; ; i++ {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		val, err := yamlToJSON(&doc)
+		if err != nil {
+			return nil, err
+		}
+		if val == nil {
+			continue
+		}
+		msg, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("YAML document %d is not a mapping", i)
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func yamlToJSON(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlToJSON(node.Content[0])
+	case yaml.AliasNode:
+		return yamlToJSON(node.Alias)
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, elem := range node.Content {
+			val, err := yamlToJSON(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		return list, nil
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, val := node.Content[i], node.Content[i+1]
+			if key.Tag == "!!merge" {
+				if err := mergeYAML(m, val); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			v, err := yamlToJSON(val)
+			if err != nil {
+				return nil, err
+			}
+			m[key.Value] = v
+		}
+		return m, nil
+	}
+	switch node.ShortTag() {
+	case "!!binary":
+		return strings.Join(strings.Fields(node.Value), ""), nil
+	case "!!timestamp":
+		var t time.Time
+		if err := node.Decode(&t); err != nil {
+			return nil, err
+		}
+		return t.Format(time.RFC3339Nano), nil
+	}
+	var val interface{}
+	if err := node.Decode(&val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// mergeYAML adds the entries of the mappings merged with a `<<` key that m doesn't have yet.
+func mergeYAML(m map[string]interface{}, node *yaml.Node) error {
+	val, err := yamlToJSON(node)
+	if err != nil {
+		return err
+	}
+	merged := []interface{}{val}
+	if list, ok := val.([]interface{}); ok {
+		merged = list
+	}
+	for _, elem := range merged {
+		entries, _ := elem.(map[string]interface{})
+		for k, v := range entries {
+			if _, ok := m[k]; !ok {
+				m[k] = v
+			}
+		}
+	}
+	return nil
+}
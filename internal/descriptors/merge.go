@@ -0,0 +1,55 @@
+package descriptors
+
+import "google.golang.org/protobuf/reflect/protoreflect"
+
+// MergeJSON merges overlay into the JSON message base of type md, with the values of overlay taking precedence.
+// Messages and maps are merged key by key, other values are replaced.
+// Fields are matched by their JSON or proto names, and setting a member of a oneof removes the other members from base.
+func MergeJSON(md protoreflect.MessageDescriptor, base, overlay map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		base = map[string]interface{}{}
+	}
+	for key, val := range overlay {
+		field := fieldByName(md, key)
+		if field == nil {
+			base[key] = val
+			continue
+		}
+		for _, name := range []string{field.JSONName(), string(field.Name())} {
+			if existing, ok := base[name]; ok && name != key {
+				delete(base, name)
+				base[key] = existing
+			}
+		}
+		if oneof := field.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() {
+			for i := 0; i < oneof.Fields().Len(); i++ {
+				if member := oneof.Fields().Get(i); member.Number() != field.Number() {
+					delete(base, member.JSONName())
+					delete(base, string(member.Name()))
+				}
+			}
+		}
+		existing, existingObj := base[key].(map[string]interface{})
+		obj, isObj := val.(map[string]interface{})
+		switch {
+		case !existingObj || !isObj:
+			base[key] = val
+		case field.IsMap():
+			for k, v := range obj {
+				existing[k] = v
+			}
+		case field.Message() != nil && !IsWellKnown(field.Message()):
+			base[key] = MergeJSON(field.Message(), existing, obj)
+		default:
+			base[key] = val
+		}
+	}
+	return base
+}
+
+func fieldByName(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if field := md.Fields().ByJSONName(name); field != nil {
+		return field
+	}
+	return md.Fields().ByName(protoreflect.Name(name))
+}
@@ -1,9 +1,10 @@
-//nolint
+// nolint
 package descriptors
 
 import (
 	"encoding/json"
 
+	"google.golang.org/genproto/googleapis/api/annotations"
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -13,6 +14,7 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
+// MakeJSONTemplate returns the example message of MakeTemplate as a JSON object and as a string.
 // Adapted from https://github.com/fullstorydev/grpcurl/blob/de25c898228e36e8539862ed08de69598e64cb76/grpcurl.go#L400
 func MakeJSONTemplate(md protoreflect.MessageDescriptor) (map[string]interface{}, string) {
 	toString, err := protojson.Marshal(MakeTemplate(md, nil))
@@ -27,6 +29,10 @@ func MakeJSONTemplate(md protoreflect.MessageDescriptor) (map[string]interface{}
 	return m, string(toString)
 }
 
+// MakeTemplate returns an example message of type md, with a sample value in every field that can be set in a request.
+// Repeated fields get one element and maps one entry, one member is picked for every oneof, and fields annotated as
+// OUTPUT_ONLY are left out. Messages that are already in path, the messages that contain md, are left empty to end
+// recursion.
 func MakeTemplate(md protoreflect.MessageDescriptor, path []protoreflect.MessageDescriptor) proto.Message {
 	switch md.FullName() {
 	case "google.protobuf.Any":
@@ -67,128 +73,89 @@ func MakeTemplate(md protoreflect.MessageDescriptor, path []protoreflect.Message
 		}
 	}
 	dm := dynamicpb.NewMessage(md)
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		// 2006-01-02T15:04:05Z, as RFC 3339 doesn't allow the year 1.
+		dm.Set(md.Fields().ByName("seconds"), protoreflect.ValueOfInt64(1136214245))
+		return dm
+	case "google.protobuf.Duration":
+		dm.Set(md.Fields().ByName("seconds"), protoreflect.ValueOfInt64(1))
+		return dm
+	}
 	for _, seen := range path {
-		if seen == md {
+		if seen.FullName() == md.FullName() {
 			return dm
 		}
 	}
-	for i := 0; i < dm.Descriptor().Fields().Len(); i++ {
-		fd := dm.Descriptor().Fields().Get(i)
-		var val protoreflect.Value
-		switch fd.Kind() {
-		case protoreflect.BoolKind:
-			val = protoreflect.ValueOfBool(true)
-		case protoreflect.EnumKind:
-			val = protoreflect.ValueOfEnum(1)
-		case protoreflect.Int32Kind:
-			val = protoreflect.ValueOfInt32(1)
-		case protoreflect.Sint32Kind:
-			val = protoreflect.ValueOfInt32(1)
-		case protoreflect.Uint32Kind:
-			val = protoreflect.ValueOfInt32(1)
-		case protoreflect.Int64Kind:
-			val = protoreflect.ValueOfInt64(1)
-		case protoreflect.Sint64Kind:
-			val = protoreflect.ValueOfInt64(1)
-		case protoreflect.Uint64Kind:
-			val = protoreflect.ValueOfInt64(1)
-		case protoreflect.Sfixed32Kind:
-			val = protoreflect.ValueOfInt32(1)
-		case protoreflect.Fixed32Kind:
-			val = protoreflect.ValueOfFloat32(1.1)
-		case protoreflect.FloatKind:
-			val = protoreflect.ValueOfFloat32(1.1)
-		case protoreflect.Sfixed64Kind:
-			val = protoreflect.ValueOfInt64(1)
-		case protoreflect.Fixed64Kind:
-			val = protoreflect.ValueOfFloat64(1.1)
-		case protoreflect.DoubleKind:
-			val = protoreflect.ValueOfFloat64(1.1)
-		case protoreflect.StringKind:
-			val = protoreflect.ValueOfString("string")
-		case protoreflect.BytesKind:
-			val = protoreflect.ValueOfBytes([]byte(fd.JSONName()))
-		case protoreflect.MessageKind:
-			val = protoreflect.ValueOfMessage(MakeTemplate(fd.Message(), nil).ProtoReflect())
-		default:
-			return dm
+	path = append(path[:len(path):len(path)], md)
+	for i := 0; i < md.Fields().Len(); i++ {
+		fd := md.Fields().Get(i)
+		if HasFieldBehavior(fd, annotations.FieldBehavior_OUTPUT_ONLY) {
+			continue
 		}
-		if fd.Cardinality() == protoreflect.Repeated {
-			val = protoreflect.ValueOfList(&List{vals: []protoreflect.Value{val}})
+		if oneof := fd.ContainingOneof(); oneof != nil && !oneof.IsSynthetic() && oneofMember(oneof) != fd {
 			continue
 		}
-
-		// TODO: this is a bug in the billingctl example
-		if fd.JSONName() == "crc32c" {
-			return dm
+		switch {
+		case fd.IsMap():
+			dm.Mutable(fd).Map().Set(templateValue(fd.MapKey(), path).MapKey(), templateValue(fd.MapValue(), path))
+		case fd.IsList():
+			dm.Mutable(fd).List().Append(templateValue(fd, path))
+		default:
+			dm.Set(fd, templateValue(fd, path))
 		}
-		dm.Set(fd, val)
 	}
 	return dm
 }
 
-type List struct {
-	vals []protoreflect.Value
-}
-
-// Len reports the number of entries in the List.
-// Get, Set, and Truncate panic with out of bound indexes.
-func (l *List) Len() int {
-	return len(l.vals)
-}
-
-// Get retrieves the value at the given index.
-// It never returns an invalid value.
-func (l *List) Get(i int) protoreflect.Value {
-	return l.vals[i]
-}
-
-// Set stores a value for the given index.
-// When setting a composite type, it is unspecified whether the set
-// value aliases the source's memory in any way.
-//
-// Set is a mutating operation and unsafe for concurrent use.
-func (l *List) Set(i int, val protoreflect.Value) {
-	l.vals[i] = val
-}
-
-// Append appends the provided value to the end of the list.
-// When appending a composite type, it is unspecified whether the appended
-// value aliases the source's memory in any way.
-//
-// Append is a mutating operation and unsafe for concurrent use.
-func (l *List) Append(v protoreflect.Value) {
-	l.vals = append(l.vals, v)
-}
-
-// AppendMutable appends a new, empty, mutable message value to the end
-// of the list and returns it.
-// It panics if the list does not contain a message type.
-func (l *List) AppendMutable() protoreflect.Value {
-	return protoreflect.ValueOfMessage(nil)
-}
-
-// Truncate truncates the list to a smaller length.
-//
-// Truncate is a mutating operation and unsafe for concurrent use.
-func (l *List) Truncate(i int) {
-	l.vals = l.vals[0:i]
-}
-
-// NewElement returns a new value for a list element.
-// For enums, this returns the first enum value.
-// For other scalars, this returns the zero value.
-// For messages, this returns a new, empty, mutable value.
-func (l *List) NewElement() protoreflect.Value {
-	return protoreflect.ValueOfMessage(nil)
+// oneofMember returns the member of a oneof that is set in templates, the first member that isn't deprecated or
+// OUTPUT_ONLY.
+func oneofMember(oneof protoreflect.OneofDescriptor) protoreflect.FieldDescriptor {
+	var member protoreflect.FieldDescriptor
+	for i := oneof.Fields().Len() - 1; i >= 0; i-- {
+		fd := oneof.Fields().Get(i)
+		if HasFieldBehavior(fd, annotations.FieldBehavior_OUTPUT_ONLY) {
+			continue
+		}
+		if member == nil || !Deprecated(fd) {
+			member = fd
+		}
+	}
+	return member
 }
 
-// IsValid reports whether the list is valid.
-//
-// An invalid list is an empty, read-only value.
-//
-// Validity is not part of the protobuf data model, and may not
-// be preserved in marshaling or other operations.
-func (l *List) IsValid() bool {
-	return true
+// templateValue returns the sample value of a single field, list element or map key or value.
+func templateValue(fd protoreflect.FieldDescriptor, path []protoreflect.MessageDescriptor) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(true)
+	case protoreflect.EnumKind:
+		// The first value is usually the unspecified zero value, so the second one makes a better example.
+		values := fd.Enum().Values()
+		if values.Len() > 1 {
+			return protoreflect.ValueOfEnum(values.Get(1).Number())
+		}
+		return protoreflect.ValueOfEnum(values.Get(0).Number())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(1)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(1)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(1)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(1)
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(1.1)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(1.1)
+	case protoreflect.StringKind:
+		if fd.ContainingMessage().IsMapEntry() && fd.Number() == 1 {
+			return protoreflect.ValueOfString("key")
+		}
+		return protoreflect.ValueOfString("string")
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(fd.JSONName()))
+	default:
+		return protoreflect.ValueOfMessage(MakeTemplate(fd.Message(), path).ProtoReflect())
+	}
 }
@@ -1,11 +1,17 @@
 package descriptors
 
 import (
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
@@ -15,6 +21,23 @@ type DataValue struct {
 	Proto bool              `json:"-"`
 	Value interface{}       `json:"value"`
 	Empty bool              `json:"-"`
+	Usage string            `json:"-"`
+	// Repeated is set for repeated fields, whose flags can be given multiple times.
+	Repeated bool `json:"-"`
+	// Enum is the descriptor of enum values, which are accepted by name or number.
+	Enum protoreflect.EnumDescriptor `json:"-"`
+	// Message is the descriptor of message values, which are parsed by ParseWellKnown if they are well known types.
+	Message protoreflect.MessageDescriptor `json:"-"`
+	// Map is set for map fields, whose flags are given as key=value multiple times.
+	// Kind is then the kind of the map values.
+	Map    bool              `json:"-"`
+	MapKey protoreflect.Kind `json:"-"`
+	// Stdin returns the reader that bytes values given as `-` are read from.
+	Stdin func() io.Reader `json:"-"`
+	// Path is the JSON field name path of the value in the request.
+	Path []string `json:"-"`
+	// Deprecated is the message shown when the flag is used, if it is deprecated.
+	Deprecated string `json:"-"`
 }
 
 type DataMap map[string]*DataValue
@@ -30,7 +53,20 @@ func (d DataMap) ToInterfaceMap() map[string]interface{} {
 		if val.Empty {
 			continue
 		}
-		jsonVal[key] = val.Value
+		path := val.Path
+		if len(path) == 0 {
+			path = []string{key}
+		}
+		obj := jsonVal
+		for _, p := range path[:len(path)-1] {
+			child, ok := obj[p].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				obj[p] = child
+			}
+			obj = child
+		}
+		obj[path[len(path)-1]] = val.Value
 	}
 	return jsonVal
 }
@@ -49,22 +85,17 @@ func ToInterfaceMap(v interface{}) (map[string]interface{}, error) {
 }
 
 func (v *DataValue) String() string {
+	if v.Empty && (v.Repeated || v.Map) {
+		// pflag only leaves out default values that are empty strings, which these aren't when formatted.
+		return ""
+	}
 	return fmt.Sprintf("%v", v.Value)
 }
 
 func (v *DataValue) Set(val string) error {
 	var err error
 	if !v.Proto {
-		switch reflect.TypeOf(v.Value).Kind() {
-		case reflect.Map:
-			vals := strings.Split(val, "=")
-			if len(vals) != 2 {
-				return fmt.Errorf("map type should be length of 2")
-			}
-			v.Value = map[string]interface{}{vals[0]: vals[1]}
-			v.Empty = false
-			return nil
-		case reflect.Bool:
+		if reflect.TypeOf(v.Value).Kind() == reflect.Bool {
 			v.Value, err = strconv.ParseBool(val)
 			v.Empty = false
 			return err
@@ -84,43 +115,196 @@ func (v *DataValue) Set(val string) error {
 		v.Empty = false
 		return nil
 	}
+	if v.Map {
+		return v.setMapEntry(val)
+	}
+	if v.Repeated {
+		return v.appendList(val)
+	}
+	v.Value, err = v.parse(val)
+	v.Empty = false
+	return err
+}
+
+// appendList appends the elements of val to a repeated value.
+// Scalar elements can be given as a comma separated list, message elements are given one per flag as JSON.
+// Elements that contain commas or newlines are quoted as in CSV, eg `"a,b",c`, other quotes are kept as they are.
+// An empty value is a single empty element.
+func (v *DataValue) appendList(val string) error {
+	elems := []string{val}
+	if v.Kind != protoreflect.MessageKind && v.Kind != protoreflect.GroupKind && val != "" {
+		r := csv.NewReader(strings.NewReader(val))
+		r.LazyQuotes, r.FieldsPerRecord = true, -1
+		records, err := r.ReadAll()
+		if err == nil && len(records) != 1 {
+			err = errors.New("it has more than one line")
+		}
+		if err != nil {
+			return fmt.Errorf(`invalid comma separated list, quote elements that contain commas or newlines as in "a,b",c: %w`, err)
+		}
+		elems = records[0]
+	}
+	list, _ := v.Value.([]interface{})
+	for _, elem := range elems {
+		parsed, err := v.parse(elem)
+		if err != nil {
+			return err
+		}
+		list = append(list, parsed)
+	}
+	v.Value = list
+	v.Empty = false
+	return nil
+}
+
+// setMapEntry adds an entry given as key=value to a map value.
+// The key is parsed as MapKey and the value as Kind, message values are given as JSON.
+func (v *DataValue) setMapEntry(val string) error {
+	key, value, ok := strings.Cut(val, "=")
+	if !ok {
+		return fmt.Errorf("%q is not in the form key=value", val)
+	}
+	parsedKey, err := parseScalar(v.MapKey, key)
+	if err != nil {
+		return fmt.Errorf("invalid %s key %q: %w", v.MapKey, key, err)
+	}
+	parsedValue, err := v.parse(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s value for key %q: %w", v.Kind, key, err)
+	}
+	m, _ := v.Value.(map[string]interface{})
+	if m == nil {
+		m = map[string]interface{}{}
+	}
+	// JSON object keys are always strings, so keys are formatted back after being validated.
+	m[fmt.Sprint(parsedKey)] = parsedValue
+	v.Value = m
+	v.Empty = false
+	return nil
+}
+
+// parse parses a single value of the DataValue's kind.
+func (v *DataValue) parse(val string) (interface{}, error) {
 	switch v.Kind {
+	case protoreflect.BytesKind:
+		return v.parseBytes(val)
+	case protoreflect.EnumKind:
+		if v.Enum != nil {
+			return ParseEnum(v.Enum, val)
+		}
+	case protoreflect.GroupKind, protoreflect.MessageKind:
+		if v.Message != nil && v.Message.FullName() == "google.protobuf.BytesValue" {
+			return v.parseBytes(val)
+		}
+		if v.Message != nil && IsWellKnown(v.Message) {
+			return ParseWellKnown(v.Message, val, time.Now())
+		}
+		if trimmed := strings.TrimSpace(val); strings.HasPrefix(trimmed, "{") && json.Valid([]byte(trimmed)) {
+			return json.RawMessage(trimmed), nil
+		}
+		if v.Repeated || v.Map {
+			return nil, fmt.Errorf("%q is not a JSON object", val)
+		}
+		return val, nil
+	}
+	return parseScalar(v.Kind, val)
+}
+
+// parseBytes parses a bytes value with ParseBytes and encodes it for JSON.
+func (v *DataValue) parseBytes(val string) (interface{}, error) {
+	var stdin io.Reader
+	if v.Stdin != nil {
+		stdin = v.Stdin()
+	}
+	b, err := ParseBytes(val, stdin)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// parseScalar parses a value of a scalar kind.
+func parseScalar(kind protoreflect.Kind, val string) (interface{}, error) {
+	switch kind {
 	case protoreflect.BoolKind:
-		v.Value, err = strconv.ParseBool(val)
+		return strconv.ParseBool(val)
 	case protoreflect.EnumKind:
-		v.Value, err = strconv.ParseInt(val, 10, 64)
-	case protoreflect.Int32Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 32)
-	case protoreflect.Sint32Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 32)
-	case protoreflect.Uint32Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 32)
-	case protoreflect.Int64Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 64)
-	case protoreflect.Sint64Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 64)
-	case protoreflect.Uint64Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 64)
-	case protoreflect.Sfixed32Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 32)
-	case protoreflect.Fixed32Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 32)
-	case protoreflect.FloatKind:
-		v.Value, err = strconv.ParseFloat(val, 64)
-	case protoreflect.Sfixed64Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 64)
-	case protoreflect.Fixed64Kind:
-		v.Value, err = strconv.ParseInt(val, 10, 64)
-	case protoreflect.DoubleKind:
-		v.Value, err = strconv.ParseFloat(val, 64)
-	case protoreflect.StringKind:
-		v.Value = val
-	case protoreflect.BytesKind, protoreflect.GroupKind, protoreflect.MessageKind:
-		v.Value = val
+		return strconv.ParseInt(val, 10, 64)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return strconv.ParseInt(val, 10, 32)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return strconv.ParseUint(val, 10, 32)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.ParseInt(val, 10, 64)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.ParseUint(val, 10, 64)
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return strconv.ParseFloat(val, 64)
+	case protoreflect.BytesKind:
+		b, err := ParseBytes(val, nil)
+		if err != nil {
+			return nil, err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
 	}
-	return err
+	return val, nil
+}
+
+// typeName returns the name of a well known type as a flag type, eg `timestamp` or `timestampSlice`.
+func (v *DataValue) typeName() string {
+	name := strings.ToLower(string(v.Message.Name()))
+	switch {
+	case v.Map:
+		return fmt.Sprintf("map[%s]%s", v.MapKey, name)
+	case v.Repeated:
+		return name + "Slice"
+	}
+	return name
 }
 
 func (v *DataValue) Type() string {
+	if v.Map {
+		return fmt.Sprintf("map[%s]%s", v.MapKey, v.Kind)
+	}
+	if v.Message != nil && IsWellKnown(v.Message) {
+		return v.typeName()
+	}
+	if v.Repeated {
+		// cobra keeps offering flags with a Slice type in completions after they have been set.
+		return v.Kind.String() + "Slice"
+	}
 	return v.Kind.String()
 }
+
+// ParseEnum parses an enum value from its number or its name.
+// Names are matched case-insensitively, with or without the enum's type prefix, eg `STATE_ACTIVE`, `active`.
+// Names are returned as the value's canonical name, numbers as int64.
+func ParseEnum(enum protoreflect.EnumDescriptor, val string) (interface{}, error) {
+	if number, err := strconv.ParseInt(val, 10, 32); err == nil {
+		return number, nil
+	}
+	prefix := EnumPrefix(enum)
+	values := enum.Values()
+	names := make([]string, 0, values.Len())
+	for i := 0; i < values.Len(); i++ {
+		name := string(values.Get(i).Name())
+		if strings.EqualFold(val, name) || strings.EqualFold(prefix+val, name) {
+			return name, nil
+		}
+		names = append(names, name)
+	}
+	return nil, fmt.Errorf("invalid value %q for enum %s, expected one of: %s", val, enum.FullName(), strings.Join(names, ", "))
+}
+
+// EnumPrefix returns the prefix that enum value names conventionally start with, eg `BILLING_STATE_` for BillingState.
+func EnumPrefix(enum protoreflect.EnumDescriptor) string {
+	var b strings.Builder
+	name := []rune(string(enum.Name()))
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && (unicode.IsLower(name[i-1]) || (i+1 < len(name) && unicode.IsLower(name[i+1]))) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String() + "_"
+}
@@ -0,0 +1,141 @@
+package descriptors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// IsWellKnown reports whether a message is a well known type that can be parsed from a flag by ParseWellKnown.
+func IsWellKnown(md protoreflect.MessageDescriptor) bool {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration", "google.protobuf.FieldMask",
+		"google.protobuf.Struct", "google.protobuf.Value", "google.protobuf.ListValue", "google.protobuf.Any":
+		return true
+	}
+	return isWrapper(md)
+}
+
+func isWrapper(md protoreflect.MessageDescriptor) bool {
+	return md.ParentFile() != nil && md.ParentFile().Path() == "google/protobuf/wrappers.proto"
+}
+
+// ParseWellKnown parses a flag value of a well known type into its JSON representation:
+//   - Timestamp: RFC3339, a date, `now`, or `now` plus or minus a Go duration, eg `now-1h`
+//   - Duration: a Go duration, eg `1h30m`
+//   - FieldMask: a comma separated list of paths, eg `display_name,billingAccount.open`
+//   - wrappers: the wrapped scalar value, eg `5` for Int32Value
+//   - Struct, ListValue, Value and Any: JSON, where Any needs an `@type`.
+func ParseWellKnown(md protoreflect.MessageDescriptor, val string, now time.Time) (interface{}, error) {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		t, err := ParseTimestamp(val, now)
+		if err != nil {
+			return nil, err
+		}
+		return marshalJSON(timestamppb.New(t))
+	case "google.protobuf.Duration":
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, err
+		}
+		return marshalJSON(durationpb.New(d))
+	case "google.protobuf.FieldMask":
+		var paths []string
+		for _, path := range strings.Split(val, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, snakeCase(path))
+			}
+		}
+		return marshalJSON(&fieldmaskpb.FieldMask{Paths: paths})
+	case "google.protobuf.Struct":
+		return parseJSON(val, "{")
+	case "google.protobuf.ListValue":
+		return parseJSON(val, "[")
+	case "google.protobuf.Value":
+		if json.Valid([]byte(val)) {
+			return json.RawMessage(val), nil
+		}
+		return val, nil
+	case "google.protobuf.Any":
+		raw, err := parseJSON(val, "{")
+		if err != nil {
+			return nil, err
+		}
+		var any map[string]interface{}
+		if err := json.Unmarshal(raw, &any); err != nil {
+			return nil, err
+		}
+		if _, ok := any["@type"]; !ok {
+			return nil, fmt.Errorf("%q has no @type", val)
+		}
+		return raw, nil
+	}
+	if value := md.Fields().ByName("value"); isWrapper(md) && value != nil {
+		return parseScalar(value.Kind(), val)
+	}
+	return nil, fmt.Errorf("%s can't be parsed from a flag", md.FullName())
+}
+
+// ParseTimestamp parses an RFC3339 timestamp, a date, `now`, or `now` plus or minus a Go duration, eg `now-1h`.
+func ParseTimestamp(val string, now time.Time) (time.Time, error) {
+	if rel := strings.TrimPrefix(val, "now"); rel != val {
+		if rel == "" {
+			return now, nil
+		}
+		if !strings.HasPrefix(rel, "-") && !strings.HasPrefix(rel, "+") {
+			return time.Time{}, fmt.Errorf("invalid relative timestamp %q, expected eg now-1h", val)
+		}
+		d, err := time.ParseDuration(rel)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse("2006-01-02", val); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339Nano, val)
+}
+
+func parseJSON(val, prefix string) (json.RawMessage, error) {
+	trimmed := strings.TrimSpace(val)
+	if !strings.HasPrefix(trimmed, prefix) || !json.Valid([]byte(trimmed)) {
+		kind := "object"
+		if prefix == "[" {
+			kind = "array"
+		}
+		return nil, fmt.Errorf("%q is not a JSON %s", val, kind)
+	}
+	return json.RawMessage(trimmed), nil
+}
+
+func marshalJSON(m proto.Message) (json.RawMessage, error) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(b), nil
+}
+
+// snakeCase converts a lowerCamelCase field path, as used in JSON field masks, into its proto field names.
+func snakeCase(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			b.WriteRune('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
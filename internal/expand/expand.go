@@ -0,0 +1,114 @@
+// Package expand expands the placeholders of request files and headers.
+package expand
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Vars are the variables available to placeholders, typically read from a --vars file.
+type Vars map[string]interface{}
+
+// ReadVars reads variables from a YAML or JSON file of key value pairs.
+func ReadVars(path string) (Vars, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	vars := Vars{}
+	if err := yaml.Unmarshal(b, &vars); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// Expand expands the placeholders in b. Go templates are executed first, with the variables as their data and these
+// functions:
+//
+//	now      the current time
+//	rfc3339  formats a time as RFC 3339, eg {{ now | rfc3339 }}
+//	unix     formats a time as seconds since the epoch
+//	uuid     a random version 4 UUID
+//	env      the value of an environment variable, eg {{ env "USER" }}
+//
+// Then ${NAME} is replaced by the variable NAME, or else by the environment variable NAME, and $${NAME} by ${NAME}.
+// Using a variable that is undefined in both is an error.
+// Values are inserted as they are, so values in JSON strings must not contain quotes.
+func Expand(b []byte, vars Vars) ([]byte, error) {
+	if bytes.Contains(b, []byte("{{")) {
+		templ, err := template.New("request").Option("missingkey=error").Funcs(funcs()).Parse(string(b))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := templ.Execute(&buf, vars); err != nil {
+			return nil, err
+		}
+		b = buf.Bytes()
+	}
+	var undefined []string
+	b = variable.ReplaceAllFunc(b, func(match []byte) []byte {
+		if bytes.HasPrefix(match, []byte("$$")) {
+			return match[1:]
+		}
+		name := string(match[2 : len(match)-1])
+		if val, ok := vars[name]; ok {
+			return []byte(fmt.Sprint(val))
+		}
+		if val, ok := os.LookupEnv(name); ok {
+			return []byte(val)
+		}
+		undefined = append(undefined, name)
+		return match
+	})
+	if len(undefined) > 0 {
+		return nil, fmt.Errorf("undefined variable(s) %s", strings.Join(undefined, ", "))
+	}
+	return b, nil
+}
+
+// String expands the placeholders in s like Expand.
+func String(s string, vars Vars) (string, error) {
+	b, err := Expand([]byte(s), vars)
+	return string(b), err
+}
+
+var variable = regexp.MustCompile(`\$?\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+func funcs() template.FuncMap {
+	return template.FuncMap{
+		"now": time.Now,
+		"rfc3339": func(t time.Time) string {
+			return t.Format(time.RFC3339)
+		},
+		"unix": func(t time.Time) int64 {
+			return t.Unix()
+		},
+		"uuid": uuid,
+		"env": func(name string) (string, error) {
+			if val, ok := os.LookupEnv(name); ok {
+				return val, nil
+			}
+			return "", fmt.Errorf("undefined environment variable %s", name)
+		},
+	}
+}
+
+// uuid returns a random version 4 UUID.
+func uuid() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
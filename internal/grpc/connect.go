@@ -7,88 +7,127 @@ import (
 
 	"github.com/bufbuild/connect-go"
 	"github.com/joshcarp/grpctl/internal/descriptors"
+	"github.com/joshcarp/grpctl/internal/validate"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
-	"google.golang.org/protobuf/reflect/protoregistry"
 	"google.golang.org/protobuf/types/dynamicpb"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
-func CallUnary(ctx context.Context, addr string, method protoreflect.MethodDescriptor, inputData []byte, protocol string, http1 bool) ([]byte, error) {
-	dynamicRequest := dynamicpb.NewMessage(method.Input())
-	err := protojson.Unmarshal(inputData, dynamicRequest)
+// CallUnary calls a unary method with a JSON request, parsed with options, and returns its response as a dynamic
+// message of the method's output type.
+func CallUnary(
+	ctx context.Context,
+	addr string,
+	method protoreflect.MethodDescriptor,
+	inputData []byte,
+	protocol string,
+	http1, validateRequest bool,
+	options protojson.UnmarshalOptions,
+) (proto.Message, error) {
+	options, err := withResolver(options, method.ParentFile())
 	if err != nil {
 		return nil, err
 	}
-	requestBytes, err := proto.Marshal(dynamicRequest)
+	request, err := ParseMessage(inputData, method.Input(), options, validateRequest)
 	if err != nil {
 		return nil, err
 	}
-	request := &emptypb.Empty{}
-	if err := proto.Unmarshal(requestBytes, request); err != nil {
-		return nil, err
-	}
 	connectReq := connect.NewRequest(request)
 	if md, ok := metadata.FromOutgoingContext(ctx); ok {
 		for key, val := range md {
 			connectReq.Header().Set(key, val[0])
 		}
 	}
-	fqnAddr := addr + descriptors.FullMethod(method)
-	var clientOpts []connect.ClientOption
-	switch protocol {
-	case "grpc":
-		clientOpts = append(clientOpts, connect.WithGRPC())
-	case "grpcweb":
-		clientOpts = append(clientOpts, connect.WithGRPCWeb())
-	case "connect":
-	default:
-	}
-	client := connect.NewClient[emptypb.Empty, emptypb.Empty](client(http1, plaintext(addr)), fqnAddr, clientOpts...)
-	var registry protoregistry.Types
-	if err := registry.RegisterMessage(dynamicpb.NewMessageType(method.Output())); err != nil {
-		return nil, err
-	}
-	if err := registry.RegisterMessage(dynamicpb.NewMessageType(method.Input())); err != nil {
-		return nil, err
-	}
-	response, err := client.CallUnary(ctx, connectReq)
+	response, err := getClient(addr, method, protocol, http1).CallUnary(ctx, connectReq)
 	if err != nil {
 		return nil, err
 	}
-	responseBytes, err := proto.Marshal(response.Msg)
+	return toDynamic(response.Msg, method.Output())
+}
+
+// toDynamic converts a message received as emptypb.Empty, which keeps its fields as unknown fields, into a dynamic
+// message of type md.
+func toDynamic(msg *emptypb.Empty, md protoreflect.MessageDescriptor) (proto.Message, error) {
+	b, err := proto.Marshal(msg)
 	if err != nil {
 		return nil, err
 	}
-	dynamicResponse := dynamicpb.NewMessage(method.Output())
-	if err := proto.Unmarshal(responseBytes, dynamicResponse); err != nil {
+	dynamicResponse := dynamicpb.NewMessage(md)
+	if err := proto.Unmarshal(b, dynamicResponse); err != nil {
 		return nil, err
 	}
-	return protojson.MarshalOptions{Resolver: &registry, Multiline: true, Indent: " "}.Marshal(dynamicResponse)
+	return dynamicResponse, nil
 }
 
-func ParseMessage(inputJSON []byte, messageDesc protoreflect.MessageDescriptor) (*emptypb.Empty, error) {
-	dynamicRequest := dynamicpb.NewMessage(messageDesc)
-	err := protojson.Unmarshal(inputJSON, dynamicRequest)
+// withResolver returns options with a Resolver for the types of file, unless they already have one.
+func withResolver(options protojson.UnmarshalOptions, file protoreflect.FileDescriptor) (protojson.UnmarshalOptions, error) {
+	if options.Resolver != nil {
+		return options, nil
+	}
+	resolver, err := NewResolver(file)
+	if err != nil {
+		return options, err
+	}
+	options.Resolver = resolver
+	return options, nil
+}
+
+// ParseMessage parses a JSON message of type messageDesc with options. Without a Resolver in options, one is built
+// for the types of messageDesc's file, so callers that parse many messages should set it. If validateRequest is set,
+// the message is validated against the protovalidate or protoc-gen-validate constraints in its descriptor.
+func ParseMessage(
+	inputJSON []byte,
+	messageDesc protoreflect.MessageDescriptor,
+	options protojson.UnmarshalOptions,
+	validateRequest bool,
+) (*emptypb.Empty, error) {
+	options, err := withResolver(options, messageDesc.ParentFile())
 	if err != nil {
 		return nil, err
 	}
-	requestBytes, err := proto.Marshal(dynamicRequest)
+	dynamicRequest := dynamicpb.NewMessage(messageDesc)
+	if err := options.Unmarshal(inputJSON, dynamicRequest); err != nil {
+		return nil, err
+	}
+	if validateRequest {
+		if err := validate.Message(dynamicRequest); err != nil {
+			return nil, err
+		}
+	}
+	requestBytes, err := proto.MarshalOptions{AllowPartial: options.AllowPartial}.Marshal(dynamicRequest)
 	if err != nil {
 		return nil, err
 	}
 	request := &emptypb.Empty{}
-	if err := proto.Unmarshal(requestBytes, request); err != nil {
+	if err := (proto.UnmarshalOptions{AllowPartial: options.AllowPartial}).Unmarshal(requestBytes, request); err != nil {
 		return nil, err
 	}
 	return request, nil
 }
 
-func Send(inputJSON chan []byte, messageDescriptor protoreflect.MessageDescriptor, f func(*emptypb.Empty) error) error {
+// ParseText parses a message of type messageDesc in the protobuf text format and returns it as JSON. The types of
+// google.protobuf.Any values are looked up in resolver, see NewResolver.
+func ParseText(inputText []byte, messageDesc protoreflect.MessageDescriptor, resolver *Resolver) ([]byte, error) {
+	msg := dynamicpb.NewMessage(messageDesc)
+	if err := (prototext.UnmarshalOptions{Resolver: resolver}).Unmarshal(inputText, msg); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{Resolver: resolver}.Marshal(msg)
+}
+
+func Send(
+	inputJSON chan []byte,
+	messageDescriptor protoreflect.MessageDescriptor,
+	options protojson.UnmarshalOptions,
+	validateRequest bool,
+	f func(*emptypb.Empty) error,
+) error {
 	for inputs := range inputJSON {
-		request, err := ParseMessage(inputs, messageDescriptor)
+		request, err := ParseMessage(inputs, messageDescriptor, options, validateRequest)
 		if err != nil {
 			return err
 		}
@@ -100,8 +139,9 @@ func Send(inputJSON chan []byte, messageDescriptor protoreflect.MessageDescripto
 	return nil
 }
 
-func Receive(outputJSON chan []byte, method protoreflect.MethodDescriptor, f func() (*emptypb.Empty, error)) error {
-	defer close(outputJSON)
+// Receive sends the responses returned by f to output, until f returns io.EOF or a nil response, or ctx is done
+// because nothing reads output anymore.
+func Receive(ctx context.Context, output chan proto.Message, method protoreflect.MethodDescriptor, f func() (*emptypb.Empty, error)) error {
 	for {
 		msg, err := f()
 		if errors.Is(err, io.EOF) {
@@ -113,54 +153,66 @@ func Receive(outputJSON chan []byte, method protoreflect.MethodDescriptor, f fun
 		if msg == nil {
 			break
 		}
-		responseBytes, err := proto.Marshal(msg)
-		if err != nil {
-			return nil
-		}
-		dynamicResponse := dynamicpb.NewMessage(method.Output())
-		if err := proto.Unmarshal(responseBytes, dynamicResponse); err != nil {
-			return err
-		}
-		reg, err := registry(method)
+		response, err := toDynamic(msg, method.Output())
 		if err != nil {
 			return err
 		}
-		b, err := protojson.MarshalOptions{Resolver: &reg, Multiline: true, Indent: " "}.Marshal(dynamicResponse)
-		if err != nil {
-			return err
+		select {
+		case output <- response:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
-		outputJSON <- b
 	}
 	return nil
 }
 
-func CallStreaming(ctx context.Context, addr string, method protoreflect.MethodDescriptor, protocol string, http1 bool, inputJSON, outputJSON chan []byte) error {
+// CallStreaming calls a streaming method with the JSON requests of inputJSON, and sends its responses to output, which
+// is closed when the call ends. The error of the call, including one that ends a stream of responses, is returned.
+func CallStreaming(
+	ctx context.Context,
+	addr string,
+	method protoreflect.MethodDescriptor,
+	protocol string,
+	http1, validateRequest bool,
+	options protojson.UnmarshalOptions,
+	inputJSON chan []byte,
+	output chan proto.Message,
+) error {
+	defer close(output)
+	// The resolver is built once for all the requests of the call.
+	options, err := withResolver(options, method.ParentFile())
+	if err != nil {
+		return err
+	}
 	client := getClient(addr, method, protocol, http1)
 	if method.IsStreamingClient() && method.IsStreamingServer() { //nolint:gocritic
 		stream := client.CallBidiStream(ctx)
-		if err := Send(inputJSON, method.Input(), stream.Send); err != nil {
+		// Send returns io.EOF if the server ended the call, whose error is returned by receiving.
+		if err := Send(inputJSON, method.Input(), options, validateRequest, stream.Send); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if err := stream.CloseRequest(); err != nil {
 			return err
 		}
-		if err := Receive(outputJSON, method, stream.Receive); err != nil {
+		if err := Receive(ctx, output, method, stream.Receive); err != nil {
 			return err
 		}
+		return stream.CloseResponse()
 	} else if method.IsStreamingClient() {
 		stream := client.CallClientStream(ctx)
-		if err := Send(inputJSON, method.Input(), stream.Send); err != nil {
+		// Send returns io.EOF if the server ended the call, whose error is returned by receiving.
+		if err := Send(inputJSON, method.Input(), options, validateRequest, stream.Send); err != nil && !errors.Is(err, io.EOF) {
 			return err
 		}
-		err := Receive(outputJSON, method, func() (*emptypb.Empty, error) {
+		return Receive(ctx, output, method, func() (*emptypb.Empty, error) {
 			resp, err := stream.CloseAndReceive()
 			if err != nil {
 				return nil, err
 			}
 			return resp.Msg, err
 		})
-		if err != nil {
-			return err
-		}
 	} else if method.IsStreamingServer() {
-		req, err := ParseMessage(<-inputJSON, method.Input())
+		req, err := ParseMessage(<-inputJSON, method.Input(), options, validateRequest)
 		if err != nil {
 			return err
 		}
@@ -168,7 +220,7 @@ func CallStreaming(ctx context.Context, addr string, method protoreflect.MethodD
 		if err != nil {
 			return err
 		}
-		err = Receive(outputJSON, method, func() (*emptypb.Empty, error) {
+		err = Receive(ctx, output, method, func() (*emptypb.Empty, error) {
 			if stream.Receive() {
 				return stream.Msg(), nil
 			}
@@ -177,21 +229,14 @@ func CallStreaming(ctx context.Context, addr string, method protoreflect.MethodD
 		if err != nil {
 			return err
 		}
+		if err := stream.Err(); err != nil {
+			return err
+		}
+		return stream.Close()
 	}
 	return nil
 }
 
-func registry(method protoreflect.MethodDescriptor) (protoregistry.Types, error) {
-	var registry protoregistry.Types
-	if err := registry.RegisterMessage(dynamicpb.NewMessageType(method.Output())); err != nil {
-		return protoregistry.Types{}, err
-	}
-	if err := registry.RegisterMessage(dynamicpb.NewMessageType(method.Input())); err != nil {
-		return protoregistry.Types{}, err
-	}
-	return registry, nil
-}
-
 func getClient(addr string, method protoreflect.MethodDescriptor, protocol string, http1 bool) *connect.Client[emptypb.Empty, emptypb.Empty] {
 	fqnAddr := addr + descriptors.FullMethod(method)
 	var clientOpts []connect.ClientOption
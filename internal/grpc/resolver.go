@@ -0,0 +1,90 @@
+package grpc
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Resolver resolves the types of a file and everything it imports, so that google.protobuf.Any values of types that
+// are only known through reflection can be marshalled. Types that aren't found fall back to protoregistry.GlobalTypes.
+type Resolver struct {
+	types protoregistry.Types
+}
+
+// NewResolver returns a Resolver of the types of file and the files it imports.
+func NewResolver(file protoreflect.FileDescriptor) (*Resolver, error) {
+	r := &Resolver{}
+	if err := r.registerFile(file, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Resolver) registerFile(file protoreflect.FileDescriptor, seen map[string]bool) error {
+	if seen[file.Path()] {
+		return nil
+	}
+	seen[file.Path()] = true
+	if err := r.registerMessages(file.Messages()); err != nil {
+		return err
+	}
+	for i := 0; i < file.Extensions().Len(); i++ {
+		if err := r.types.RegisterExtension(dynamicpb.NewExtensionType(file.Extensions().Get(i))); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < file.Imports().Len(); i++ {
+		if err := r.registerFile(file.Imports().Get(i).FileDescriptor, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) registerMessages(messages protoreflect.MessageDescriptors) error {
+	for i := 0; i < messages.Len(); i++ {
+		message := messages.Get(i)
+		if err := r.types.RegisterMessage(dynamicpb.NewMessageType(message)); err != nil {
+			return err
+		}
+		if err := r.registerMessages(message.Messages()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) FindMessageByName(message protoreflect.FullName) (protoreflect.MessageType, error) {
+	mt, err := r.types.FindMessageByName(message)
+	if errors.Is(err, protoregistry.NotFound) {
+		return protoregistry.GlobalTypes.FindMessageByName(message)
+	}
+	return mt, err
+}
+
+func (r *Resolver) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	mt, err := r.types.FindMessageByURL(url)
+	if errors.Is(err, protoregistry.NotFound) {
+		return protoregistry.GlobalTypes.FindMessageByURL(url)
+	}
+	return mt, err
+}
+
+func (r *Resolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	xt, err := r.types.FindExtensionByName(field)
+	if errors.Is(err, protoregistry.NotFound) {
+		return protoregistry.GlobalTypes.FindExtensionByName(field)
+	}
+	return xt, err
+}
+
+func (r *Resolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	xt, err := r.types.FindExtensionByNumber(message, field)
+	if errors.Is(err, protoregistry.NotFound) {
+		return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+	}
+	return xt, err
+}
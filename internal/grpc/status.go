@@ -0,0 +1,198 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bufbuild/connect-go"
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails" // registers the standard error details
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// StatusError is the error of a failed call, with the details of its google.rpc.Status decoded.
+type StatusError struct {
+	err      *connect.Error
+	status   *spb.Status
+	details  []proto.Message
+	resolver *Resolver
+}
+
+// DecodeStatus returns the error of a failed call as a StatusError, whose details are decoded with the types of file
+// and the standard error details. Other errors are returned as they are.
+func DecodeStatus(err error, file protoreflect.FileDescriptor) error {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return err
+	}
+	resolver, resolverErr := NewResolver(file)
+	if resolverErr != nil {
+		return err
+	}
+	status := &spb.Status{Code: int32(connectErr.Code()), Message: connectErr.Message()}
+	for _, detail := range connectErr.Details() {
+		status.Details = append(status.Details, &anypb.Any{TypeUrl: "type.googleapis.com/" + detail.Type(), Value: detail.Bytes()})
+	}
+	if len(status.Details) == 0 {
+		// Details that the client didn't decode are still in the trailers of gRPC responses.
+		if b, decodeErr := base64.RawStdEncoding.DecodeString(strings.TrimRight(connectErr.Meta().Get("Grpc-Status-Details-Bin"), "=")); decodeErr == nil {
+			trailer := &spb.Status{}
+			if proto.Unmarshal(b, trailer) == nil {
+				status.Details = trailer.Details
+			}
+		}
+	}
+	e := &StatusError{err: connectErr, status: status, resolver: resolver}
+	for _, detail := range status.Details {
+		e.details = append(e.details, decodeDetail(detail, resolver))
+	}
+	return e
+}
+
+// decodeDetail returns a detail as a message of its type, or nil if its type is unknown.
+func decodeDetail(detail *anypb.Any, resolver *Resolver) proto.Message {
+	mt, err := resolver.FindMessageByURL(detail.TypeUrl)
+	if err != nil {
+		return nil
+	}
+	msg := mt.New().Interface()
+	if err := proto.Unmarshal(detail.Value, msg); err != nil {
+		return nil
+	}
+	return msg
+}
+
+// Code returns the code of the status.
+func (e *StatusError) Code() connect.Code {
+	return e.err.Code()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.err
+}
+
+// Error returns the code and message of the status, followed by a line for each detail.
+func (e *StatusError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.err.Code().String())
+	if e.status.Message != "" {
+		b.WriteString(": " + e.status.Message)
+	}
+	for i, detail := range e.details {
+		if detail == nil {
+			fmt.Fprintf(&b, "\n  %s: unknown detail type", strings.TrimPrefix(e.status.Details[i].TypeUrl, "type.googleapis.com/"))
+			continue
+		}
+		name := detail.ProtoReflect().Descriptor().Name()
+		for _, line := range formatDetail(detail.ProtoReflect()) {
+			fmt.Fprintf(&b, "\n  %s: %s", name, line)
+		}
+	}
+	return b.String()
+}
+
+// Status returns the status as a google.rpc.Status.
+func (e *StatusError) Status() *spb.Status {
+	return e.status
+}
+
+// Resolver returns the resolver of the types of the details of the status.
+func (e *StatusError) Resolver() *Resolver {
+	return e.resolver
+}
+
+// formatDetail returns the lines of a detail. The standard error details are formatted by their fields, which works
+// for both generated and dynamic messages, and other messages are formatted in the protobuf text format.
+func formatDetail(m protoreflect.Message) []string {
+	var lines []string
+	switch m.Descriptor().FullName() {
+	case "google.rpc.ErrorInfo":
+		line := fmt.Sprintf("%s (%s)", stringField(m, "reason"), stringField(m, "domain"))
+		if metadata := mapField(m, "metadata"); len(metadata) > 0 {
+			line += " " + strings.Join(metadata, " ")
+		}
+		lines = append(lines, line)
+	case "google.rpc.BadRequest":
+		lines = listField(m, "field_violations", func(v protoreflect.Message) string {
+			return stringField(v, "field") + ": " + stringField(v, "description")
+		})
+	case "google.rpc.RetryInfo":
+		if delay := messageField(m, "retry_delay"); delay != nil {
+			d := time.Duration(intField(delay, "seconds"))*time.Second + time.Duration(intField(delay, "nanos"))
+			lines = append(lines, "retry after "+d.String())
+		}
+	case "google.rpc.QuotaFailure":
+		lines = listField(m, "violations", func(v protoreflect.Message) string {
+			return stringField(v, "subject") + ": " + stringField(v, "description")
+		})
+	case "google.rpc.PreconditionFailure":
+		lines = listField(m, "violations", func(v protoreflect.Message) string {
+			return stringField(v, "type") + " " + stringField(v, "subject") + ": " + stringField(v, "description")
+		})
+	}
+	if len(lines) == 0 {
+		lines = append(lines, prototext.MarshalOptions{}.Format(m.Interface()))
+	}
+	return lines
+}
+
+func stringField(m protoreflect.Message, name protoreflect.Name) string {
+	if fd := m.Descriptor().Fields().ByName(name); fd != nil && fd.Kind() == protoreflect.StringKind && !fd.IsList() && !fd.IsMap() {
+		return m.Get(fd).String()
+	}
+	return ""
+}
+
+func intField(m protoreflect.Message, name protoreflect.Name) int64 {
+	fd := m.Descriptor().Fields().ByName(name)
+	if fd == nil || fd.IsList() {
+		return 0
+	}
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Int64Kind:
+		return m.Get(fd).Int()
+	}
+	return 0
+}
+
+func messageField(m protoreflect.Message, name protoreflect.Name) protoreflect.Message {
+	if fd := m.Descriptor().Fields().ByName(name); fd != nil && fd.Message() != nil && !fd.IsList() && !fd.IsMap() && m.Has(fd) {
+		return m.Get(fd).Message()
+	}
+	return nil
+}
+
+func listField(m protoreflect.Message, name protoreflect.Name, format func(protoreflect.Message) string) []string {
+	fd := m.Descriptor().Fields().ByName(name)
+	if fd == nil || !fd.IsList() || fd.Message() == nil {
+		return nil
+	}
+	var lines []string
+	list := m.Get(fd).List()
+	for i := 0; i < list.Len(); i++ {
+		lines = append(lines, format(list.Get(i).Message()))
+	}
+	return lines
+}
+
+// mapField returns the entries of a map field as sorted key=value pairs.
+func mapField(m protoreflect.Message, name protoreflect.Name) []string {
+	fd := m.Descriptor().Fields().ByName(name)
+	if fd == nil || !fd.IsMap() {
+		return nil
+	}
+	var entries []string
+	m.Get(fd).Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+		entries = append(entries, key.String()+"="+value.String())
+		return true
+	})
+	sort.Strings(entries)
+	return entries
+}
@@ -0,0 +1,191 @@
+package output
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// ANSI colors of the parts of JSON and YAML output.
+const (
+	colorReset  = "\x1b[0m"
+	colorKey    = "\x1b[34;1m"
+	colorString = "\x1b[32m"
+	colorNumber = "\x1b[36m"
+	colorBool   = "\x1b[33m"
+	colorNull   = "\x1b[90m"
+)
+
+// colorize colors JSON and YAML output, and returns other formats as they are.
+func (p *Printer) colorize(b []byte) []byte {
+	if !p.Color {
+		return b
+	}
+	switch p.format {
+	case JSON, JSONCompact:
+		return colorJSON(b)
+	case YAML:
+		return colorYAML(b)
+	}
+	return b
+}
+
+// colorJSON colors the keys and values of JSON text.
+func colorJSON(b []byte) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(b); {
+		var end int
+		var color string
+		switch c := b[i]; {
+		case c == '"':
+			end, color = stringEnd(b, i), colorString
+			if isKey(b[end:]) {
+				color = colorKey
+			}
+		case c == '-' || c >= '0' && c <= '9':
+			end, color = i+1, colorNumber
+			for end < len(b) && strings.IndexByte("+-.eE0123456789", b[end]) >= 0 {
+				end++
+			}
+		case bytes.HasPrefix(b[i:], []byte("true")):
+			end, color = i+len("true"), colorBool
+		case bytes.HasPrefix(b[i:], []byte("false")):
+			end, color = i+len("false"), colorBool
+		case bytes.HasPrefix(b[i:], []byte("null")):
+			end, color = i+len("null"), colorNull
+		default:
+			buf.WriteByte(c)
+			i++
+			continue
+		}
+		writeColored(&buf, color, b[i:end])
+		i = end
+	}
+	return buf.Bytes()
+}
+
+// stringEnd returns the index after the JSON string that starts at b[start].
+func stringEnd(b []byte, start int) int {
+	for i := start + 1; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(b)
+}
+
+// isKey returns whether the JSON text after a string starts with a colon, which makes the string a key.
+func isKey(b []byte) bool {
+	// protojson randomly adds non-breaking spaces to its output.
+	rest := bytes.TrimLeft(b, " \t\r\n\u00a0")
+	return len(rest) > 0 && rest[0] == ':'
+}
+
+// colorYAML colors the keys and scalars of block style YAML, line by line. The lines of block scalars are colored as
+// strings.
+func colorYAML(b []byte) []byte {
+	var buf bytes.Buffer
+	blockIndent := -1
+	for _, line := range strings.SplitAfter(string(b), "\n") {
+		content := strings.TrimSuffix(line, "\n")
+		newline := line[len(content):]
+		trimmed := strings.TrimLeft(content, " ")
+		indent := len(content) - len(trimmed)
+		if trimmed == "" || trimmed == "---" {
+			buf.WriteString(line)
+			continue
+		}
+		if blockIndent >= 0 {
+			if indent > blockIndent {
+				buf.WriteString(content[:indent])
+				writeColored(&buf, colorString, []byte(trimmed))
+				buf.WriteString(newline)
+				continue
+			}
+			blockIndent = -1
+		}
+		buf.WriteString(content[:indent])
+		for strings.HasPrefix(trimmed, "- ") {
+			buf.WriteString("- ")
+			trimmed = trimmed[len("- "):]
+		}
+		value := trimmed
+		if key, rest, ok := splitKey(trimmed); ok {
+			writeColored(&buf, colorKey, []byte(key))
+			buf.WriteString(":")
+			value = rest
+		}
+		if strings.HasPrefix(strings.TrimSpace(value), "|") || strings.HasPrefix(strings.TrimSpace(value), ">") {
+			blockIndent = indent
+			buf.WriteString(value)
+		} else {
+			colorScalar(&buf, value)
+		}
+		buf.WriteString(newline)
+	}
+	return buf.Bytes()
+}
+
+// splitKey splits a YAML mapping entry into its key and the rest of the line after the colon.
+func splitKey(s string) (string, string, bool) {
+	start := 0
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		start = quotedEnd(s)
+	}
+	if i := strings.Index(s[start:], ": "); i >= 0 {
+		return s[:start+i], s[start+i+1:], true
+	}
+	if strings.HasSuffix(s, ":") && len(s) > start {
+		return s[:len(s)-1], "", true
+	}
+	return "", "", false
+}
+
+// quotedEnd returns the index after the quoted YAML scalar that s starts with.
+func quotedEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// colorScalar writes the value of a YAML entry, colored by its type.
+func colorScalar(buf *bytes.Buffer, value string) {
+	scalar := strings.TrimLeft(value, " ")
+	buf.WriteString(value[:len(value)-len(scalar)])
+	var color string
+	switch {
+	case scalar == "", scalar == "{}", scalar == "[]":
+		buf.WriteString(scalar)
+		return
+	case scalar == "true", scalar == "false":
+		color = colorBool
+	case scalar == "null", scalar == "~":
+		color = colorNull
+	case scalar[0] == '"', scalar[0] == '\'':
+		color = colorString
+	default:
+		color = colorString
+		if _, err := strconv.ParseFloat(scalar, 64); err == nil {
+			color = colorNumber
+		}
+	}
+	writeColored(buf, color, []byte(scalar))
+}
+
+func writeColored(buf *bytes.Buffer, color string, b []byte) {
+	buf.WriteString(color)
+	buf.Write(b)
+	buf.WriteString(colorReset)
+}
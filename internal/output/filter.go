@@ -0,0 +1,114 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/itchyny/gojq"
+	"google.golang.org/protobuf/proto"
+)
+
+// Filter reshapes messages before they are written, with a jq query or a Go template. Both are given the message as
+// it is represented in JSON.
+type Filter struct {
+	query    *gojq.Code
+	template *template.Template
+	// Raw writes the strings that a query returns without quotes, like `jq --raw-output`.
+	Raw bool
+}
+
+// ParseQuery returns a Filter that runs a jq query.
+func ParseQuery(query string) (*Filter, error) {
+	parsed, err := gojq.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{query: code}, nil
+}
+
+// ParseTemplate returns a Filter that executes a Go template, eg `{{ .name }}`.
+func ParseTemplate(text string) (*Filter, error) {
+	templ, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter{template: templ}, nil
+}
+
+// SetFilter filters the messages that are written, which can only be written as JSON or YAML.
+func (p *Printer) SetFilter(filter *Filter) error {
+	switch p.format {
+	case JSON, JSONCompact, YAML:
+		p.filter = filter
+		return nil
+	}
+	return fmt.Errorf("filtered output can't be written as %s", p.format)
+}
+
+// printFiltered writes the results of the filter for a message.
+func (p *Printer) printFiltered(msg proto.Message) error {
+	options := p.options
+	options.Multiline, options.Indent = false, ""
+	b, err := options.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	if err := json.Unmarshal(b, &value); err != nil {
+		return err
+	}
+	if p.filter.template != nil {
+		var buf bytes.Buffer
+		if err := p.filter.template.Execute(&buf, value); err != nil {
+			return err
+		}
+		return p.write(buf.Bytes())
+	}
+	iter := p.filter.query.Run(value)
+	for {
+		result, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if err, ok := result.(error); ok {
+			return fmt.Errorf("jq: %w", err)
+		}
+		b, err := p.marshalValue(result)
+		if err != nil {
+			return err
+		}
+		if err := p.write(b); err != nil {
+			return err
+		}
+	}
+}
+
+// marshalValue marshals a result of a query in the printer's format.
+func (p *Printer) marshalValue(value interface{}) ([]byte, error) {
+	if s, ok := value.(string); ok && p.filter.Raw {
+		return []byte(s), nil
+	}
+	var b []byte
+	var err error
+	switch p.format {
+	case JSONCompact:
+		b, err = json.Marshal(value)
+	case YAML:
+		b, err = marshalYAML(value)
+		if p.Stream {
+			b = append([]byte("---\n"), b...)
+		}
+	default:
+		b, err = json.MarshalIndent(value, "", " ")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p.colorize(b), nil
+}
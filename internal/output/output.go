@@ -0,0 +1,174 @@
+// Package output writes response messages in the formats of the --output flag.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	// JSON is multiline protojson.
+	JSON Format = "json"
+	// JSONCompact is protojson on a single line.
+	JSONCompact Format = "json-compact"
+	// YAML is protojson converted to YAML.
+	YAML Format = "yaml"
+	// Text is the protobuf text format.
+	Text Format = "prototext"
+	// Binary is the protobuf wire format. Streamed messages are each prefixed by their length as a varint.
+	Binary Format = "binary"
+	// Table has a row for every message, or for every element of the list in a message such as the resources of
+	// an AIP List response, and a column for every field.
+	Table Format = "table"
+)
+
+// Formats returns all output formats.
+func Formats() []string {
+	return []string{string(JSON), string(JSONCompact), string(YAML), string(Text), string(Binary), string(Table)}
+}
+
+// ParseFormat returns the format named s.
+func ParseFormat(s string) (Format, error) {
+	for _, format := range Formats() {
+		if s == format {
+			return Format(s), nil
+		}
+	}
+	return "", fmt.Errorf("unknown output format %q, expected one of %s", s, strings.Join(Formats(), ", "))
+}
+
+// Printer writes messages to w in a format. Close must be called after the last message, as tables are only
+// written once all their rows are known.
+type Printer struct {
+	w       io.Writer
+	format  Format
+	options protojson.MarshalOptions
+	// Stream is set when more than one message may be written, so that binary messages are delimited.
+	Stream bool
+	// Width is the width that tables are fit into, usually that of the terminal, or 0 for no limit.
+	Width int
+	// Color colors JSON and YAML output with ANSI escape sequences.
+	Color  bool
+	filter *Filter
+	table  *table
+}
+
+// NewPrinter returns a Printer that writes messages to w in format. The options are used for all JSON based formats,
+// their Resolver resolves the types of google.protobuf.Any values.
+func NewPrinter(w io.Writer, format Format, options protojson.MarshalOptions) *Printer {
+	return &Printer{w: w, format: format, options: options}
+}
+
+// Print writes a message.
+func (p *Printer) Print(msg proto.Message) error {
+	if p.filter != nil {
+		return p.printFiltered(msg)
+	}
+	switch p.format {
+	case Table:
+		return p.addRows(msg)
+	case Binary:
+		b, err := Marshal(msg, Binary, p.options)
+		if err != nil {
+			return err
+		}
+		if p.Stream {
+			b = append(protowire.AppendVarint(nil, uint64(len(b))), b...)
+		}
+		_, err = p.w.Write(b)
+		return err
+	}
+	b, err := Marshal(msg, p.format, p.options)
+	if err != nil {
+		return err
+	}
+	if p.format == YAML && p.Stream {
+		b = append([]byte("---\n"), b...)
+	}
+	return p.write(p.colorize(b))
+}
+
+// write writes text, ending it with a newline.
+func (p *Printer) write(b []byte) error {
+	if !bytes.HasSuffix(b, []byte("\n")) {
+		b = append(b, '\n')
+	}
+	_, err := p.w.Write(b)
+	return err
+}
+
+// Marshal marshals a message in a format other than Table. The options are used for JSON and YAML, apart from their
+// layout, and only their Resolver and AllowPartial are used for the other formats.
+func Marshal(msg proto.Message, format Format, options protojson.MarshalOptions) ([]byte, error) {
+	switch format {
+	case JSON:
+		options.Multiline, options.Indent = true, " "
+		return options.Marshal(msg)
+	case JSONCompact:
+		options.Multiline, options.Indent = false, ""
+		b, err := options.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		// protojson randomly adds spaces to its output, compacting removes them.
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, b); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case YAML:
+		options.Multiline, options.Indent = false, ""
+		b, err := options.Marshal(msg)
+		if err != nil {
+			return nil, err
+		}
+		return jsonToYAML(b)
+	case Text:
+		return prototext.MarshalOptions{Resolver: options.Resolver, AllowPartial: options.AllowPartial, Multiline: true, Indent: "  "}.Marshal(msg)
+	case Binary:
+		return proto.MarshalOptions{AllowPartial: options.AllowPartial, Deterministic: true}.Marshal(msg)
+	}
+	return nil, fmt.Errorf("can't marshal a single message as %s", format)
+}
+
+// jsonToYAML converts JSON into block style YAML, keeping the order of the fields.
+func jsonToYAML(b []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	resetStyle(&node)
+	return marshalYAML(&node)
+}
+
+func marshalYAML(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
@@ -0,0 +1,245 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joshcarp/grpctl/internal/descriptors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// minColumnWidth is the width that columns are not shrunk below to fit a table into the terminal.
+const minColumnWidth = 8
+
+// table collects the rows of a table, which are written when the printer is closed.
+type table struct {
+	// list is the repeated field whose elements are the rows, or nil if messages are the rows.
+	list    protoreflect.FieldDescriptor
+	headers []string
+	columns [][]protoreflect.FieldDescriptor
+	rows    [][]string
+}
+
+// SetColumns sets the columns of tables of messages of type md to the fields at paths, such as `name` or
+// `billing_account.display_name`, which can use JSON or proto field names. Tables of messages that have a repeated
+// message field, such as AIP List responses, have a row for every element of that field, and the paths are
+// relative to its message. Without paths, there is a column for every field.
+func (p *Printer) SetColumns(md protoreflect.MessageDescriptor, paths []string) error {
+	t := &table{list: ListField(md)}
+	row := md
+	if t.list != nil {
+		row = t.list.Message()
+	}
+	if len(paths) == 0 {
+		for i := 0; i < row.Fields().Len(); i++ {
+			field := row.Fields().Get(i)
+			t.headers = append(t.headers, strings.ToUpper(string(field.Name())))
+			t.columns = append(t.columns, []protoreflect.FieldDescriptor{field})
+		}
+	}
+	for _, path := range paths {
+		fields, err := fieldPath(row, path)
+		if err != nil {
+			return err
+		}
+		t.headers = append(t.headers, strings.ToUpper(path))
+		t.columns = append(t.columns, fields)
+	}
+	p.table = t
+	return nil
+}
+
+// ListField returns the repeated message field whose elements are the rows of tables of md, or nil if it has none.
+// An AIP List response has one, next to scalar fields such as its next_page_token.
+func ListField(md protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	for i := 0; i < md.Fields().Len(); i++ {
+		field := md.Fields().Get(i)
+		if field.IsList() && field.Message() != nil {
+			return field
+		}
+	}
+	return nil
+}
+
+// ColumnPaths returns the paths of the columns that tables of md can have, with the fields of nested messages.
+func ColumnPaths(md protoreflect.MessageDescriptor) []string {
+	if list := ListField(md); list != nil {
+		md = list.Message()
+	}
+	return columnPaths(md, "", []protoreflect.MessageDescriptor{md})
+}
+
+func columnPaths(md protoreflect.MessageDescriptor, prefix string, seen []protoreflect.MessageDescriptor) []string {
+	var paths []string
+	for i := 0; i < md.Fields().Len(); i++ {
+		field := md.Fields().Get(i)
+		path := prefix + field.JSONName()
+		paths = append(paths, path)
+		if field.Message() == nil || field.IsList() || field.IsMap() || descriptors.IsWellKnown(field.Message()) || contains(seen, field.Message()) {
+			continue
+		}
+		paths = append(paths, columnPaths(field.Message(), path+".", append(seen, field.Message()))...)
+	}
+	return paths
+}
+
+// fieldPath returns the fields of a dotted path in md.
+func fieldPath(md protoreflect.MessageDescriptor, path string) ([]protoreflect.FieldDescriptor, error) {
+	var fields []protoreflect.FieldDescriptor
+	for _, name := range strings.Split(path, ".") {
+		var field protoreflect.FieldDescriptor
+		if md != nil {
+			field = md.Fields().ByJSONName(name)
+			if field == nil {
+				field = md.Fields().ByName(protoreflect.Name(name))
+			}
+		}
+		if field == nil {
+			return nil, fmt.Errorf("unknown column %q, expected one of %s", path, strings.Join(ColumnPaths(md), ", "))
+		}
+		fields = append(fields, field)
+		md = nil
+		if !field.IsList() && !field.IsMap() {
+			md = field.Message()
+		}
+	}
+	return fields, nil
+}
+
+// addRows adds the rows of a message to the table.
+func (p *Printer) addRows(msg proto.Message) error {
+	if p.table == nil {
+		if err := p.SetColumns(msg.ProtoReflect().Descriptor(), nil); err != nil {
+			return err
+		}
+	}
+	// Cells are looked up by JSON name, so only the options that don't change the names are used.
+	options := protojson.MarshalOptions{Resolver: p.options.Resolver, AllowPartial: p.options.AllowPartial, UseEnumNumbers: p.options.UseEnumNumbers}
+	b, err := options.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(b, &values); err != nil {
+		return err
+	}
+	rows := []interface{}{values}
+	if p.table.list != nil {
+		rows, _ = values[p.table.list.JSONName()].([]interface{})
+	}
+	for _, row := range rows {
+		cells := make([]string, 0, len(p.table.columns))
+		for _, column := range p.table.columns {
+			cell, err := formatCell(lookup(row, column))
+			if err != nil {
+				return err
+			}
+			cells = append(cells, cell)
+		}
+		p.table.rows = append(p.table.rows, cells)
+	}
+	return nil
+}
+
+func lookup(val interface{}, fields []protoreflect.FieldDescriptor) interface{} {
+	for _, field := range fields {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		val = obj[field.JSONName()]
+	}
+	return val
+}
+
+// formatCell formats a JSON value as a table cell, with objects and arrays as compact JSON.
+func formatCell(val interface{}) (string, error) {
+	switch val := val.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.ReplaceAll(val, "\n", " "), nil
+	case map[string]interface{}, []interface{}:
+		b, err := json.Marshal(val)
+		return string(b), err
+	}
+	return fmt.Sprint(val), nil
+}
+
+// Close writes the table of the messages that have been printed.
+func (p *Printer) Close() error {
+	if p.format != Table || p.table == nil {
+		return nil
+	}
+	return p.table.write(p.w, p.Width)
+}
+
+// write writes the table with columns separated by two spaces. If width is set, the widest columns are shrunk, down
+// to minColumnWidth, until the rows fit into it, and the cells that don't fit are truncated.
+func (t *table) write(w io.Writer, width int) error {
+	widths := make([]int, len(t.headers))
+	for _, row := range append([][]string{t.headers}, t.rows...) {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for width > 0 {
+		total, widest := 2*(len(widths)-1), 0
+		for i, w := range widths {
+			total += w
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if total <= width || widths[widest] <= minColumnWidth {
+			break
+		}
+		widths[widest] = maxInt(widths[widest]-(total-width), minColumnWidth)
+	}
+	for _, row := range append([][]string{t.headers}, t.rows...) {
+		var line strings.Builder
+		for i, cell := range row {
+			cell = truncate(cell, widths[i])
+			line.WriteString(cell)
+			if i < len(row)-1 {
+				line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2))
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line.String(), " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate shortens s to width runes, ending it with an ellipsis if it is cut.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:width-1]) + "…"
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func contains(mds []protoreflect.MessageDescriptor, md protoreflect.MessageDescriptor) bool {
+	for _, seen := range mds {
+		if seen.FullName() == md.FullName() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package validate
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/ext"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// cel evaluates the CEL constraints in the `cel` field of field or message constraints on val, which is bound to `this`.
+// The string extension functions of CEL are available, expressions that don't compile, such as those using the
+// custom functions of protovalidate, are skipped.
+func (v *validator) cel(constraints protoreflect.Message, file protoreflect.FileDescriptor, val interface{}, path string) {
+	list, ok := has(constraints, "cel")
+	if !ok {
+		return
+	}
+	env, err := cel.NewEnv(
+		cel.TypeDescs(file),
+		cel.Variable("this", cel.DynType),
+		cel.Variable("now", cel.TimestampType),
+		ext.Strings(),
+	)
+	if err != nil {
+		v.report(path, "cel", "can't evaluate CEL constraints: %v", err)
+		return
+	}
+	for i := 0; i < list.List().Len(); i++ {
+		constraint := list.List().Get(i).Message()
+		id := getString(constraint, "id")
+		expression := getString(constraint, "expression")
+		ast, issues := env.Compile(expression)
+		if issues.Err() != nil {
+			continue
+		}
+		program, err := env.Program(ast)
+		if err != nil {
+			continue
+		}
+		out, _, err := program.Eval(map[string]interface{}{"this": val, "now": time.Now()})
+		if err != nil {
+			v.report(path, id, "can't evaluate `%s`: %v", expression, err)
+			continue
+		}
+		switch out := out.(type) {
+		case types.Bool:
+			if !out {
+				message := getString(constraint, "message")
+				if message == "" {
+					message = fmt.Sprintf("`%s` returned false", expression)
+				}
+				v.report(path, id, "%s", message)
+			}
+		case types.String:
+			if out != "" {
+				v.report(path, id, "%s", string(out))
+			}
+		}
+	}
+}
+
+// celValue converts a field value into a value that CEL can bind to a variable.
+func celValue(val protoreflect.Value) interface{} {
+	switch val := val.Interface().(type) {
+	case protoreflect.Message:
+		return val.Interface()
+	case protoreflect.EnumNumber:
+		return int64(val)
+	case protoreflect.List:
+		list := make([]interface{}, 0, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			list = append(list, celValue(val.Get(i)))
+		}
+		return list
+	case protoreflect.Map:
+		m := make(map[interface{}]interface{}, val.Len())
+		val.Range(func(key protoreflect.MapKey, val protoreflect.Value) bool {
+			m[key.Interface()] = celValue(val)
+			return true
+		})
+		return m
+	default:
+		return val
+	}
+}
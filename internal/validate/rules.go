@@ -0,0 +1,218 @@
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func (v *validator) stringRules(rules protoreflect.Message, val, path string) {
+	if c, ok := has(rules, "const"); ok && c.String() != val {
+		v.report(path, "string.const", "value must equal `%s`", c.String())
+	}
+	length := uint64(utf8.RuneCountInString(val))
+	if l, ok := has(rules, "len"); ok && length != l.Uint() {
+		v.report(path, "string.len", "value length must be %d characters", l.Uint())
+	}
+	if min, ok := has(rules, "min_len"); ok && length < min.Uint() {
+		v.report(path, "string.min_len", "value length must be at least %d characters", min.Uint())
+	}
+	if max, ok := has(rules, "max_len"); ok && length > max.Uint() {
+		v.report(path, "string.max_len", "value length must be at most %d characters", max.Uint())
+	}
+	if l, ok := has(rules, "len_bytes"); ok && uint64(len(val)) != l.Uint() {
+		v.report(path, "string.len_bytes", "value length must be %d bytes", l.Uint())
+	}
+	if min, ok := has(rules, "min_bytes"); ok && uint64(len(val)) < min.Uint() {
+		v.report(path, "string.min_bytes", "value length must be at least %d bytes", min.Uint())
+	}
+	if max, ok := has(rules, "max_bytes"); ok && uint64(len(val)) > max.Uint() {
+		v.report(path, "string.max_bytes", "value length must be at most %d bytes", max.Uint())
+	}
+	if pattern, ok := has(rules, "pattern"); ok {
+		re, err := regexp.Compile(pattern.String())
+		switch {
+		case err != nil:
+			v.report(path, "string.pattern", "invalid pattern `%s`: %v", pattern.String(), err)
+		case !re.MatchString(val):
+			v.report(path, "string.pattern", "value does not match regex pattern `%s`", pattern.String())
+		}
+	}
+	if prefix, ok := has(rules, "prefix"); ok && !strings.HasPrefix(val, prefix.String()) {
+		v.report(path, "string.prefix", "value does not have prefix `%s`", prefix.String())
+	}
+	if suffix, ok := has(rules, "suffix"); ok && !strings.HasSuffix(val, suffix.String()) {
+		v.report(path, "string.suffix", "value does not have suffix `%s`", suffix.String())
+	}
+	if contains, ok := has(rules, "contains"); ok && !strings.Contains(val, contains.String()) {
+		v.report(path, "string.contains", "value does not contain substring `%s`", contains.String())
+	}
+	if notContains, ok := has(rules, "not_contains"); ok && strings.Contains(val, notContains.String()) {
+		v.report(path, "string.not_contains", "value contains substring `%s`", notContains.String())
+	}
+	v.in(rules, "string", protoreflect.ValueOfString(val), path)
+}
+
+func (v *validator) bytesRules(rules protoreflect.Message, val []byte, path string) {
+	if c, ok := has(rules, "const"); ok && !bytes.Equal(c.Bytes(), val) {
+		v.report(path, "bytes.const", "value must be %x", c.Bytes())
+	}
+	length := uint64(len(val))
+	if l, ok := has(rules, "len"); ok && length != l.Uint() {
+		v.report(path, "bytes.len", "value length must be %d bytes", l.Uint())
+	}
+	if min, ok := has(rules, "min_len"); ok && length < min.Uint() {
+		v.report(path, "bytes.min_len", "value length must be at least %d bytes", min.Uint())
+	}
+	if max, ok := has(rules, "max_len"); ok && length > max.Uint() {
+		v.report(path, "bytes.max_len", "value length must be at most %d bytes", max.Uint())
+	}
+	if prefix, ok := has(rules, "prefix"); ok && !bytes.HasPrefix(val, prefix.Bytes()) {
+		v.report(path, "bytes.prefix", "value does not have prefix %x", prefix.Bytes())
+	}
+	if suffix, ok := has(rules, "suffix"); ok && !bytes.HasSuffix(val, suffix.Bytes()) {
+		v.report(path, "bytes.suffix", "value does not have suffix %x", suffix.Bytes())
+	}
+	if contains, ok := has(rules, "contains"); ok && !bytes.Contains(val, contains.Bytes()) {
+		v.report(path, "bytes.contains", "value does not contain %x", contains.Bytes())
+	}
+	v.in(rules, "bytes", protoreflect.ValueOfBytes(val), path)
+}
+
+func (v *validator) enumRules(rules protoreflect.Message, field protoreflect.FieldDescriptor, val protoreflect.EnumNumber, path string) {
+	if c, ok := has(rules, "const"); ok && protoreflect.EnumNumber(c.Int()) != val {
+		v.report(path, "enum.const", "value must equal %d", c.Int())
+	}
+	if get(rules, "defined_only").Bool() && field.Enum().Values().ByNumber(val) == nil {
+		v.report(path, "enum.defined_only", "value must be one of the defined enum values")
+	}
+	v.in(rules, "enum", protoreflect.ValueOfInt32(int32(val)), path)
+}
+
+func (v *validator) numberRules(rules protoreflect.Message, name string, val protoreflect.Value, path string) {
+	if c, ok := has(rules, "const"); ok && compare(val, c) != 0 {
+		v.report(path, name+".const", "value must equal %v", c.Interface())
+	}
+	lower, lowerRule, hasLower := bound(rules, "gt", "gte")
+	upper, upperRule, hasUpper := bound(rules, "lt", "lte")
+	aboveLower := !hasLower || compare(val, lower) > 0 || (lowerRule == "gte" && compare(val, lower) == 0)
+	belowUpper := !hasUpper || compare(val, upper) < 0 || (upperRule == "lte" && compare(val, upper) == 0)
+	var valid bool
+	switch {
+	case hasLower && hasUpper && compare(lower, upper) > 0:
+		// A lower bound above the upper bound excludes the range between them.
+		valid = aboveLower || belowUpper
+	default:
+		valid = aboveLower && belowUpper
+	}
+	if !valid {
+		var bounds []string
+		rule := name + "."
+		if hasLower {
+			bounds = append(bounds, fmt.Sprintf("%s %v", describeBound(lowerRule), lower.Interface()))
+			rule += lowerRule
+		}
+		if hasUpper {
+			bounds = append(bounds, fmt.Sprintf("%s %v", describeBound(upperRule), upper.Interface()))
+			if hasLower {
+				rule += "_"
+			}
+			rule += upperRule
+		}
+		conjunction := " and "
+		if hasLower && hasUpper && compare(lower, upper) > 0 {
+			conjunction = " or "
+		}
+		v.report(path, rule, "value must be %s", strings.Join(bounds, conjunction))
+	}
+	v.in(rules, name, val, path)
+}
+
+func describeBound(rule string) string {
+	switch rule {
+	case "gt":
+		return "greater than"
+	case "gte":
+		return "greater than or equal to"
+	case "lt":
+		return "less than"
+	}
+	return "less than or equal to"
+}
+
+// bound returns the exclusive or inclusive bound of number rules, and which of them it is.
+func bound(rules protoreflect.Message, exclusive, inclusive protoreflect.Name) (protoreflect.Value, string, bool) {
+	if val, ok := has(rules, exclusive); ok {
+		return val, string(exclusive), true
+	}
+	if val, ok := has(rules, inclusive); ok {
+		return val, string(inclusive), true
+	}
+	return protoreflect.Value{}, "", false
+}
+
+// in evaluates the in and not_in rules of a value.
+func (v *validator) in(rules protoreflect.Message, name string, val protoreflect.Value, path string) {
+	if in, ok := has(rules, "in"); ok && !contains(in.List(), val) {
+		v.report(path, name+".in", "value must be in list %s", formatList(in.List()))
+	}
+	if notIn, ok := has(rules, "not_in"); ok && contains(notIn.List(), val) {
+		v.report(path, name+".not_in", "value must not be in list %s", formatList(notIn.List()))
+	}
+}
+
+func contains(list protoreflect.List, val protoreflect.Value) bool {
+	for i := 0; i < list.Len(); i++ {
+		if compare(list.Get(i), val) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func formatList(list protoreflect.List) string {
+	elems := make([]string, 0, list.Len())
+	for i := 0; i < list.Len(); i++ {
+		elems = append(elems, fmt.Sprint(list.Get(i).Interface()))
+	}
+	return "[" + strings.Join(elems, ", ") + "]"
+}
+
+// compare compares two scalar values of the same kind, returning -1, 0 or 1.
+// Values that can't be compared are reported as unequal.
+func compare(a, b protoreflect.Value) int {
+	switch a := a.Interface().(type) {
+	case int32, int64:
+		return compareOrdered(a, b, protoreflect.Value.Int)
+	case uint32, uint64:
+		return compareOrdered(a, b, protoreflect.Value.Uint)
+	case float32, float64:
+		return compareOrdered(a, b, protoreflect.Value.Float)
+	case string:
+		return strings.Compare(a, fmt.Sprint(b.Interface()))
+	case []byte:
+		other, _ := b.Interface().([]byte)
+		return bytes.Compare(a, other)
+	case bool:
+		if other, ok := b.Interface().(bool); ok && other == a {
+			return 0
+		}
+	}
+	return 1
+}
+
+func compareOrdered[T int64 | uint64 | float64](a interface{}, b protoreflect.Value, get func(protoreflect.Value) T) int {
+	x := get(protoreflect.ValueOf(a))
+	y := get(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,405 @@
+// Package validate checks messages against the constraints of protovalidate (buf.validate) and of the legacy
+// protoc-gen-validate (validate.rules) in their descriptors' options.
+//
+// The constraint extensions are resolved from the files that the message's file imports, so the generated
+// Go code of either project is not needed. Only a subset of the standard rules is evaluated:
+// required fields and oneofs, const, in and not_in, ranges of numbers, lengths, patterns, prefixes, suffixes
+// and contents of strings and bytes, defined enum values, sizes and uniqueness of repeated fields and maps,
+// and CEL expressions.
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	fieldConstraints   protoreflect.FullName = "buf.validate.field"
+	messageConstraints protoreflect.FullName = "buf.validate.message"
+	oneofConstraints   protoreflect.FullName = "buf.validate.oneof"
+	legacyRules        protoreflect.FullName = "validate.rules"
+	legacyDisabled     protoreflect.FullName = "validate.disabled"
+	legacyIgnored      protoreflect.FullName = "validate.ignored"
+	legacyRequired     protoreflect.FullName = "validate.required"
+)
+
+// Violation is a constraint that a field, or the message itself if Field is empty, doesn't satisfy.
+type Violation struct {
+	// Field is the path of the field, eg `billing_account.display_name` or `tags[1]`.
+	Field string
+	// Rule is the id of the rule, eg `string.min_len`, or the id of a CEL constraint.
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	s := v.Message
+	if v.Field != "" {
+		s = v.Field + ": " + s
+	}
+	if v.Rule != "" {
+		s += " [" + v.Rule + "]"
+	}
+	return s
+}
+
+// Error is returned by Message for a message with violations.
+type Error struct {
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	lines := []string{"invalid request:"}
+	for _, v := range e.Violations {
+		lines = append(lines, " - "+v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Message validates msg and all of the messages in it, returning an *Error listing every violation.
+func Message(msg protoreflect.Message) error {
+	v := &validator{}
+	if err := v.registerFile(msg.Descriptor().ParentFile(), map[string]bool{}); err != nil {
+		return err
+	}
+	if v.types.NumExtensions() == 0 {
+		return nil
+	}
+	v.message(msg, "")
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &Error{Violations: v.violations}
+}
+
+type validator struct {
+	types      protoregistry.Types
+	violations []Violation
+}
+
+// registerFile registers the constraint extensions declared in file and the files it imports.
+func (v *validator) registerFile(file protoreflect.FileDescriptor, seen map[string]bool) error {
+	if seen[file.Path()] {
+		return nil
+	}
+	seen[file.Path()] = true
+	for i := 0; i < file.Extensions().Len(); i++ {
+		xd := file.Extensions().Get(i)
+		switch xd.FullName() {
+		case fieldConstraints, messageConstraints, oneofConstraints, legacyRules, legacyDisabled, legacyIgnored, legacyRequired:
+			if err := v.types.RegisterExtension(dynamicpb.NewExtensionType(xd)); err != nil {
+				return err
+			}
+		}
+	}
+	for i := 0; i < file.Imports().Len(); i++ {
+		if err := v.registerFile(file.Imports().Get(i).FileDescriptor, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) report(field, rule, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{Field: field, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// extension returns the value of the extension name in the options of descriptor.
+// The options are parsed again with the registered extensions, as they are unknown fields
+// unless the generated code of the extension is linked into the binary.
+func (v *validator) extension(descriptor protoreflect.Descriptor, name protoreflect.FullName) (protoreflect.Value, bool) {
+	xt, err := v.types.FindExtensionByName(name)
+	if err != nil {
+		return protoreflect.Value{}, false
+	}
+	opts := descriptor.Options()
+	b, err := proto.Marshal(opts)
+	if err != nil || len(b) == 0 {
+		return protoreflect.Value{}, false
+	}
+	parsed := dynamicpb.NewMessage(opts.ProtoReflect().Descriptor())
+	if err := (proto.UnmarshalOptions{Resolver: &v.types}).Unmarshal(b, parsed); err != nil {
+		return protoreflect.Value{}, false
+	}
+	if !parsed.Has(xt.TypeDescriptor()) {
+		return protoreflect.Value{}, false
+	}
+	return parsed.Get(xt.TypeDescriptor()), true
+}
+
+// constraints returns the field constraints of field, from buf.validate or else validate.rules.
+func (v *validator) constraints(field protoreflect.FieldDescriptor) protoreflect.Message {
+	for _, name := range []protoreflect.FullName{fieldConstraints, legacyRules} {
+		if val, ok := v.extension(field, name); ok {
+			return val.Message()
+		}
+	}
+	return nil
+}
+
+func (v *validator) message(msg protoreflect.Message, path string) {
+	md := msg.Descriptor()
+	var constraints protoreflect.Message
+	if val, ok := v.extension(md, messageConstraints); ok {
+		constraints = val.Message()
+		if get(constraints, "disabled").Bool() {
+			return
+		}
+	}
+	for _, name := range []protoreflect.FullName{legacyDisabled, legacyIgnored} {
+		if val, ok := v.extension(md, name); ok && val.Bool() {
+			return
+		}
+	}
+	for i := 0; i < md.Oneofs().Len(); i++ {
+		v.oneof(msg, md.Oneofs().Get(i), path)
+	}
+	for i := 0; i < md.Fields().Len(); i++ {
+		v.field(msg, md.Fields().Get(i), path)
+	}
+	if constraints != nil {
+		v.cel(constraints, md.ParentFile(), msg.Interface(), path)
+	}
+}
+
+func (v *validator) oneof(msg protoreflect.Message, oneof protoreflect.OneofDescriptor, path string) {
+	if oneof.IsSynthetic() || msg.WhichOneof(oneof) != nil {
+		return
+	}
+	required := false
+	if val, ok := v.extension(oneof, oneofConstraints); ok {
+		required = get(val.Message(), "required").Bool()
+	}
+	if val, ok := v.extension(oneof, legacyRequired); ok {
+		required = required || val.Bool()
+	}
+	if required {
+		v.report(join(path, string(oneof.Name())), "required", "exactly one field is required in oneof")
+	}
+}
+
+func (v *validator) field(msg protoreflect.Message, field protoreflect.FieldDescriptor, path string) {
+	fieldPath := join(path, string(field.Name()))
+	set := msg.Has(field)
+	constraints := v.constraints(field)
+	if constraints != nil {
+		required := get(constraints, "required").Bool() || get(sub(constraints, "message"), "required").Bool()
+		if required && !set {
+			v.report(fieldPath, "required", "value is required")
+			return
+		}
+		if !ignored(constraints, field, set) {
+			v.rules(constraints, field, msg.Get(field), fieldPath)
+		}
+		if get(constraints, "skipped").Bool() || get(sub(constraints, "message"), "skip").Bool() {
+			return
+		}
+	}
+	if field.Message() == nil || !set {
+		return
+	}
+	val := msg.Get(field)
+	switch {
+	case field.IsList():
+		for i := 0; i < val.List().Len(); i++ {
+			v.message(val.List().Get(i).Message(), fmt.Sprintf("%s[%d]", fieldPath, i))
+		}
+	case field.IsMap():
+		if field.MapValue().Message() == nil {
+			return
+		}
+		val.Map().Range(func(key protoreflect.MapKey, val protoreflect.Value) bool {
+			v.message(val.Message(), mapPath(fieldPath, key))
+			return true
+		})
+	default:
+		v.message(val.Message(), fieldPath)
+	}
+}
+
+// ignored reports whether the rules of a field are skipped: if it is unset and has presence, if it is
+// explicitly ignored, or if it is empty and set to be ignored when empty.
+func ignored(constraints protoreflect.Message, field protoreflect.FieldDescriptor, set bool) bool {
+	if ignore := constraints.Descriptor().Fields().ByName("ignore"); ignore != nil && constraints.Has(ignore) {
+		if strings.HasSuffix(string(ignore.Enum().Values().ByNumber(constraints.Get(ignore).Enum()).Name()), "ALWAYS") {
+			return true
+		}
+		if !set {
+			return true
+		}
+	}
+	if !set && field.HasPresence() {
+		return true
+	}
+	_, rules := typeRules(constraints)
+	return !set && get(rules, "ignore_empty").Bool()
+}
+
+// rules evaluates the constraints of a field on its value.
+func (v *validator) rules(constraints protoreflect.Message, field protoreflect.FieldDescriptor, val protoreflect.Value, path string) {
+	name, rules := typeRules(constraints)
+	switch {
+	case field.IsList():
+		if name == "repeated" {
+			v.list(rules, field, val.List(), path)
+		}
+	case field.IsMap():
+		if name == "map" {
+			v.mapRules(rules, field, val.Map(), path)
+		}
+	default:
+		v.value(rules, name, field, val, path)
+	}
+	v.cel(constraints, field.ParentFile(), celValue(val), path)
+}
+
+// elementRules evaluates the constraints of the elements of a repeated field, or the keys or values of a map, on a single element.
+func (v *validator) elementRules(constraints protoreflect.Message, field protoreflect.FieldDescriptor, val protoreflect.Value, path string) {
+	name, rules := typeRules(constraints)
+	v.value(rules, name, field, val, path)
+	v.cel(constraints, field.ParentFile(), celValue(val), path)
+}
+
+// typeRules returns the name and the rules of the type oneof of field constraints, eg the StringRules in `string`.
+func typeRules(constraints protoreflect.Message) (string, protoreflect.Message) {
+	oneof := constraints.Descriptor().Oneofs().ByName("type")
+	if oneof == nil {
+		return "", nil
+	}
+	field := constraints.WhichOneof(oneof)
+	if field == nil || field.Message() == nil {
+		return "", nil
+	}
+	return string(field.Name()), constraints.Get(field).Message()
+}
+
+func (v *validator) list(rules protoreflect.Message, field protoreflect.FieldDescriptor, list protoreflect.List, path string) {
+	if min, ok := has(rules, "min_items"); ok && uint64(list.Len()) < min.Uint() {
+		v.report(path, "repeated.min_items", "value must contain at least %d item(s)", min.Uint())
+	}
+	if max, ok := has(rules, "max_items"); ok && uint64(list.Len()) > max.Uint() {
+		v.report(path, "repeated.max_items", "value must contain no more than %d item(s)", max.Uint())
+	}
+	if get(rules, "unique").Bool() && field.Message() == nil {
+		seen := map[interface{}]bool{}
+		for i := 0; i < list.Len(); i++ {
+			key := comparable(list.Get(i))
+			if seen[key] {
+				v.report(path, "repeated.unique", "repeated value must contain unique items")
+				break
+			}
+			seen[key] = true
+		}
+	}
+	if items, ok := has(rules, "items"); ok {
+		for i := 0; i < list.Len(); i++ {
+			v.elementRules(items.Message(), field, list.Get(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *validator) mapRules(rules protoreflect.Message, field protoreflect.FieldDescriptor, m protoreflect.Map, path string) {
+	if min, ok := has(rules, "min_pairs"); ok && uint64(m.Len()) < min.Uint() {
+		v.report(path, "map.min_pairs", "map must be at least %d entries", min.Uint())
+	}
+	if max, ok := has(rules, "max_pairs"); ok && uint64(m.Len()) > max.Uint() {
+		v.report(path, "map.max_pairs", "map must be at most %d entries", max.Uint())
+	}
+	keys, hasKeys := has(rules, "keys")
+	values, hasValues := has(rules, "values")
+	if !hasKeys && !hasValues {
+		return
+	}
+	m.Range(func(key protoreflect.MapKey, val protoreflect.Value) bool {
+		entryPath := mapPath(path, key)
+		if hasKeys {
+			v.elementRules(keys.Message(), field.MapKey(), key.Value(), entryPath)
+		}
+		if hasValues {
+			v.elementRules(values.Message(), field.MapValue(), val, entryPath)
+		}
+		return true
+	})
+}
+
+func (v *validator) value(rules protoreflect.Message, name string, field protoreflect.FieldDescriptor, val protoreflect.Value, path string) {
+	switch name {
+	case "string":
+		v.stringRules(rules, val.String(), path)
+	case "bytes":
+		v.bytesRules(rules, val.Bytes(), path)
+	case "enum":
+		v.enumRules(rules, field, val.Enum(), path)
+	case "bool":
+		if c, ok := has(rules, "const"); ok && c.Bool() != val.Bool() {
+			v.report(path, "bool.const", "value must equal %t", c.Bool())
+		}
+	case "float", "double", "int32", "int64", "uint32", "uint64", "sint32", "sint64", "fixed32", "fixed64", "sfixed32", "sfixed64":
+		v.numberRules(rules, name, val, path)
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func mapPath(path string, key protoreflect.MapKey) string {
+	if s, ok := key.Interface().(string); ok {
+		return fmt.Sprintf("%s[%q]", path, s)
+	}
+	return fmt.Sprintf("%s[%v]", path, key.Interface())
+}
+
+// get returns the value of the bool field name of m, which is false if m is nil or has no such field.
+func get(m protoreflect.Message, name protoreflect.Name) protoreflect.Value {
+	if val, ok := has(m, name); ok {
+		return val
+	}
+	return protoreflect.ValueOfBool(false)
+}
+
+// getString returns the value of the string field name of m, which is empty if m is nil or has no such field.
+func getString(m protoreflect.Message, name protoreflect.Name) string {
+	if val, ok := has(m, name); ok {
+		return val.String()
+	}
+	return ""
+}
+
+// sub returns the message field name of m, or nil if it isn't set.
+func sub(m protoreflect.Message, name protoreflect.Name) protoreflect.Message {
+	if val, ok := has(m, name); ok {
+		if msg, ok := val.Interface().(protoreflect.Message); ok {
+			return msg
+		}
+	}
+	return nil
+}
+
+// has returns the value of the field name of m if it is set.
+func has(m protoreflect.Message, name protoreflect.Name) (protoreflect.Value, bool) {
+	if m == nil {
+		return protoreflect.Value{}, false
+	}
+	field := m.Descriptor().Fields().ByName(name)
+	if field == nil || !m.Has(field) {
+		return protoreflect.Value{}, false
+	}
+	return m.Get(field), true
+}
+
+// comparable returns a value that can be used as a map key to compare scalar values.
+func comparable(val protoreflect.Value) interface{} {
+	if b, ok := val.Interface().([]byte); ok {
+		return string(b)
+	}
+	return val.Interface()
+}
@@ -2,4 +2,5 @@ package grpctl
 
 type (
 	methodDescriptorKey struct{}
+	settingsKey         struct{}
 )
@@ -0,0 +1,308 @@
+package grpctl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// PackageNaming decides how services are named when they are added as commands.
+type PackageNaming int
+
+const (
+	// ShortNames names service commands by their short name, eg `UserService Get`.
+	// Adding two services with the same short name is an error.
+	ShortNames PackageNaming = iota
+	// QualifiedNames nests service commands under a command for every segment of their package, eg `foo v1 UserService Get`.
+	QualifiedNames
+	// DisambiguatedNames names service commands by their short name, unless another service has the same short name.
+	// Colliding services are then named by their fully qualified name, eg `foo.v1.UserService Get`,
+	// with the shortest unique suffix of it as an alias, eg `v1.UserService Get`.
+	DisambiguatedNames
+)
+
+// serviceAnnotation is the cobra annotation that holds the full name of the service a command was generated from.
+const serviceAnnotation = "grpctl_service"
+
+// packageAnnotation is the cobra annotation that marks commands generated for a package segment.
+const packageAnnotation = "grpctl_package"
+
+// shortFormAnnotation is the cobra annotation of the hidden commands that keep the short names of services working
+// with QualifiedNames. It holds the qualified commands of the services with that short name.
+const shortFormAnnotation = "grpctl_short_form"
+
+// WithPackageNaming sets how services are named, see PackageNaming. The default is ShortNames.
+func WithPackageNaming(naming PackageNaming) CommandOption {
+	return func(cmd *cobra.Command) error {
+		getSettings(cmd).packageNaming = naming
+		return nil
+	}
+}
+
+// addServiceCommand adds serviceCmd generated from service to cmd, naming it according to the PackageNaming setting.
+// It returns false if a command for the same service already exists.
+func addServiceCommand(cmd, serviceCmd *cobra.Command, service protoreflect.ServiceDescriptor) (bool, error) {
+	fullName := string(service.FullName())
+	serviceCmd.Annotations = map[string]string{serviceAnnotation: fullName}
+	switch getSettings(cmd).packageNaming {
+	case QualifiedNames:
+		cmd = packageCommand(cmd, service.ParentFile().Package())
+	case DisambiguatedNames:
+		return addDisambiguated(cmd, serviceCmd), nil
+	}
+	for _, existing := range cmd.Commands() {
+		if existing.Name() != serviceCmd.Name() {
+			continue
+		}
+		if _, ok := existing.Annotations[shortFormAnnotation]; ok {
+			// A service of the root package takes the place of the short form of a service with the same name.
+			cmd.RemoveCommand(existing)
+			continue
+		}
+		if existing.Annotations[serviceAnnotation] == fullName {
+			return false, nil
+		}
+		return false, fmt.Errorf("duplicate service name: %s in %s", serviceCmd.Name(), service.ParentFile().Path())
+	}
+	cmd.AddCommand(serviceCmd)
+	return true, nil
+}
+
+// packageCommand returns the command for pkg under cmd, creating a command for every segment that doesn't exist yet.
+func packageCommand(cmd *cobra.Command, pkg protoreflect.FullName) *cobra.Command {
+	if pkg == "" {
+		return cmd
+	}
+	var prefix []string
+next:
+	for _, segment := range strings.Split(string(pkg), ".") {
+		prefix = append(prefix, segment)
+		for _, existing := range cmd.Commands() {
+			if existing.Name() == segment && existing.Annotations[packageAnnotation] != "" {
+				cmd = existing
+				continue next
+			}
+		}
+		pkgCmd := &cobra.Command{
+			Use:         segment,
+			Short:       fmt.Sprintf("Services in the %s package", strings.Join(prefix, ".")),
+			Annotations: map[string]string{packageAnnotation: strings.Join(prefix, ".")},
+		}
+		cmd.AddCommand(pkgCmd)
+		cmd = pkgCmd
+	}
+	return cmd
+}
+
+// addShortForm adds shortCmd, a hidden copy of the command of service, to cmd under the service's short name, so that
+// `UserService Get` works as well as `foo v1 UserService Get`. It returns false if the short name is already taken. When
+// another service has the same short name, the short form is replaced by an error that lists the qualified commands.
+func addShortForm(cmd, shortCmd *cobra.Command, service protoreflect.ServiceDescriptor) bool {
+	qualified := strings.Join(append(strings.Split(string(service.ParentFile().Package()), "."), shortCmd.Name()), " ")
+	for _, existing := range cmd.Commands() {
+		if existing.Name() != shortCmd.Name() {
+			continue
+		}
+		commands, ok := existing.Annotations[shortFormAnnotation]
+		if !ok {
+			return false
+		}
+		commands += ", " + qualified
+		existing.RemoveCommand(existing.Commands()...)
+		existing.Annotations[shortFormAnnotation] = commands
+		existing.DisableFlagParsing = true
+		existing.RunE = func(cmd *cobra.Command, _ []string) error {
+			return fmt.Errorf("%s is ambiguous, use one of: %s", cmd.Name(), commands)
+		}
+		return false
+	}
+	shortCmd.Hidden = true
+	shortCmd.Annotations = map[string]string{serviceAnnotation: string(service.FullName()), shortFormAnnotation: qualified}
+	cmd.AddCommand(shortCmd)
+	return true
+}
+
+// addDisambiguated adds serviceCmd to cmd under its short name, renaming every command it collides with to its
+// fully qualified name. It returns false if a command for the same service already exists.
+func addDisambiguated(cmd, serviceCmd *cobra.Command) bool {
+	short := shortName(serviceCmd.Annotations[serviceAnnotation])
+	collisions := []*cobra.Command{serviceCmd}
+	for _, existing := range cmd.Commands() {
+		fullName, ok := existing.Annotations[serviceAnnotation]
+		if !ok {
+			continue
+		}
+		if fullName == serviceCmd.Annotations[serviceAnnotation] {
+			return false
+		}
+		if shortName(fullName) == short {
+			collisions = append(collisions, existing)
+		}
+	}
+	cmd.AddCommand(serviceCmd)
+	if len(collisions) == 1 {
+		serviceCmd.Aliases = append(serviceCmd.Aliases, aliases(serviceCmd.Annotations[serviceAnnotation], serviceCmd.Name())...)
+		return true
+	}
+	fullNames := make([]string, 0, len(collisions))
+	for _, collision := range collisions {
+		fullNames = append(fullNames, collision.Annotations[serviceAnnotation])
+	}
+	for _, collision := range collisions {
+		fullName := collision.Annotations[serviceAnnotation]
+		// The last segment of the name keeps the form given by the NamingStrategy, eg foo.v1.user-service.
+		name := shortName(collision.Name())
+		pkg := strings.TrimSuffix(fullName, shortName(fullName))
+		suffix := uniqueSuffix(fullName, fullNames)
+		collision.Use = pkg + name
+		collision.Aliases = nil
+		for _, alias := range []string{fullName, strings.TrimSuffix(suffix, shortName(fullName)) + name, suffix} {
+			if alias != collision.Use && !containsString(collision.Aliases, alias) {
+				collision.Aliases = append(collision.Aliases, alias)
+			}
+		}
+	}
+	return true
+}
+
+// aliases returns alias as the only alias of a command called name, or none if they are the same.
+func aliases(alias, name string) []string {
+	if alias == name {
+		return nil
+	}
+	return []string{alias}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func shortName(fullName string) string {
+	return fullName[strings.LastIndex(fullName, ".")+1:]
+}
+
+// uniqueSuffix returns the shortest dot separated suffix of fullName that no other name in fullNames ends with.
+func uniqueSuffix(fullName string, fullNames []string) string {
+	segments := strings.Split(fullName, ".")
+next:
+	for i := len(segments) - 1; i > 0; i-- {
+		suffix := strings.Join(segments[i:], ".")
+		for _, other := range fullNames {
+			if other != fullName && (other == suffix || strings.HasSuffix(other, "."+suffix)) {
+				continue next
+			}
+		}
+		return suffix
+	}
+	return fullName
+}
+
+// NamingStrategy converts descriptor names into command and flag names.
+// The original names stay accepted as aliases.
+type NamingStrategy struct {
+	// Command returns the command name of a service or method. Commands keep their proto name if nil.
+	Command func(protoreflect.Descriptor) string
+	// Flag returns the flag name of a field. Flags are named by the field's JSON name if nil.
+	Flag func(protoreflect.FieldDescriptor) string
+}
+
+// DefaultNaming names commands by their proto name, eg `ListBillingAccounts`, and flags by their JSON name, eg `--displayName`.
+func DefaultNaming() NamingStrategy {
+	return NamingStrategy{}
+}
+
+// KebabCaseNaming names commands and flags in kebab-case, eg `list-billing-accounts` and `--display-name`.
+func KebabCaseNaming() NamingStrategy {
+	return NamingStrategy{
+		Command: func(descriptor protoreflect.Descriptor) string {
+			return kebabCase(string(descriptor.Name()))
+		},
+		Flag: func(field protoreflect.FieldDescriptor) string {
+			return kebabCase(string(field.Name()))
+		},
+	}
+}
+
+// ProtoNaming names flags by their proto field name, eg `--display_name`.
+func ProtoNaming() NamingStrategy {
+	return NamingStrategy{
+		Flag: func(field protoreflect.FieldDescriptor) string {
+			return string(field.Name())
+		},
+	}
+}
+
+// WithNamingStrategy sets how commands and flags are named, see NamingStrategy. The default is DefaultNaming.
+func WithNamingStrategy(strategy NamingStrategy) CommandOption {
+	return func(cmd *cobra.Command) error {
+		getSettings(cmd).naming = strategy
+		return nil
+	}
+}
+
+func (n NamingStrategy) command(descriptor protoreflect.Descriptor) string {
+	if n.Command == nil {
+		return string(descriptor.Name())
+	}
+	return n.Command(descriptor)
+}
+
+func (n NamingStrategy) flag(field protoreflect.FieldDescriptor) string {
+	if n.Flag == nil {
+		return field.JSONName()
+	}
+	return n.Flag(field)
+}
+
+// flagAliases returns the names a field's flag is also accepted as.
+func flagAliases(field protoreflect.FieldDescriptor) []string {
+	return []string{field.JSONName(), string(field.Name())}
+}
+
+// normalizeFlags returns a pflag normalization function that maps every alias in aliases to its flag name.
+// Dotted flags are normalized segment by segment, so aliases only need to be known for every segment.
+func normalizeFlags(aliases map[string]string) func(*pflag.FlagSet, string) pflag.NormalizedName {
+	return func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		var normalized string
+		for _, segment := range strings.Split(name, ".") {
+			next := joinFlag(normalized, segment)
+			if flagName, ok := aliases[next]; ok {
+				next = flagName
+			}
+			normalized = next
+		}
+		return pflag.NormalizedName(normalized)
+	}
+}
+
+// kebabCase converts a snake_case, camelCase or PascalCase name into kebab-case.
+func kebabCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case r == '_' || r == '-':
+			if b.Len() > 0 && i < len(runes)-1 {
+				b.WriteRune('-')
+			}
+			continue
+		case unicode.IsUpper(r) && i > 0 && runes[i-1] != '_' && runes[i-1] != '-':
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteRune('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -5,21 +5,121 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/joshcarp/grpctl/internal/output"
 	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
-// WithContextFunc will add commands to the cobra command through the file descriptors provided.
-func WithFileDescriptors(descriptors ...protoreflect.FileDescriptor) CommandOption {
+// settings are options that change how commands are generated from descriptors.
+// They are stored in the root command's context. BuildCommand generates commands after applying all of its options,
+// so that the settings apply whatever the order of the options is.
+type settings struct {
+	hideDeprecated bool
+	packageNaming  PackageNaming
+	naming         NamingStrategy
+	editor         string
+	output         string
+	marshal        protojson.MarshalOptions
+	unmarshal      protojson.UnmarshalOptions
+	// building is set while BuildCommand applies its options, which defer generating commands to pending.
+	building bool
+	pending  []func() error
+}
+
+func getSettings(cmd *cobra.Command) *settings {
+	root := cmd.Root()
+	ctx := root.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if s, ok := ctx.Value(settingsKey{}).(*settings); ok {
+		return s
+	}
+	s := &settings{}
+	root.SetContext(context.WithValue(ctx, settingsKey{}, s))
+	return s
+}
+
+// restoreSettings stores s in the root command's context again if it has been replaced, eg by cmd.ExecuteContext.
+func restoreSettings(cmd *cobra.Command, s *settings) {
+	root := cmd.Root()
+	ctx := root.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ctx.Value(settingsKey{}) != s {
+		root.SetContext(context.WithValue(ctx, settingsKey{}, s))
+	}
+}
+
+// generate runs f, which generates commands, after BuildCommand has applied all of its options, or right away
+// outside of BuildCommand.
+func generate(cmd *cobra.Command, f func() error) error {
+	s := getSettings(cmd)
+	if !s.building {
+		return f()
+	}
+	s.pending = append(s.pending, f)
+	return nil
+}
+
+// WithHideDeprecated will leave deprecated services, methods and fields out of the generated commands.
+func WithHideDeprecated() CommandOption {
 	return func(cmd *cobra.Command) error {
-		err := CommandFromFileDescriptors(cmd, descriptors...)
-		if err != nil {
+		getSettings(cmd).hideDeprecated = true
+		return nil
+	}
+}
+
+// WithEditor will open requests in editor when --edit is given, instead of $VISUAL or $EDITOR.
+// The editor can include arguments, eg `code --wait`, and is given the path of the file to edit as its last argument.
+func WithEditor(editor string) CommandOption {
+	return func(cmd *cobra.Command) error {
+		getSettings(cmd).editor = editor
+		return nil
+	}
+}
+
+// WithOutputFormat sets the default of the --output flag of method commands, which is json otherwise.
+// The format is one of json, json-compact, yaml, prototext, binary or table.
+func WithOutputFormat(format string) CommandOption {
+	return func(cmd *cobra.Command) error {
+		if _, err := output.ParseFormat(format); err != nil {
 			return err
 		}
+		getSettings(cmd).output = format
+		return nil
+	}
+}
+
+// WithMarshalOptions sets the defaults of the --emit-defaults, --use-proto-names, --enums-as-ints and --allow-partial
+// flags, which choose how responses are written as JSON or YAML. Multiline, Indent and Resolver are set by grpctl.
+func WithMarshalOptions(options protojson.MarshalOptions) CommandOption {
+	return func(cmd *cobra.Command) error {
+		getSettings(cmd).marshal = options
 		return nil
 	}
 }
 
+// WithUnmarshalOptions sets the defaults of the --discard-unknown and --allow-partial flags, which choose how
+// requests are parsed. Resolver is set by grpctl.
+func WithUnmarshalOptions(options protojson.UnmarshalOptions) CommandOption {
+	return func(cmd *cobra.Command) error {
+		getSettings(cmd).unmarshal = options
+		return nil
+	}
+}
+
+// WithFileDescriptors will add commands to the cobra command through the file descriptors provided.
+func WithFileDescriptors(descriptors ...protoreflect.FileDescriptor) CommandOption {
+	return func(cmd *cobra.Command) error {
+		return generate(cmd, func() error {
+			return CommandFromFileDescriptors(cmd, descriptors...)
+		})
+	}
+}
+
 // WithContextFunc will modify the context  before the main command is run but not in the completion stage.
 func WithContextFunc(f func(context.Context, *cobra.Command) (context.Context, error)) CommandOption {
 	return func(cmd *cobra.Command) error {
@@ -81,33 +181,29 @@ func WithArgs(args []string) CommandOption {
 // WithReflection will enable grpc reflection on the command. Use this as an alternative to WithFileDescriptors.
 func WithReflection(args []string) CommandOption {
 	return func(cmd *cobra.Command) error {
-		var err error
+		s := getSettings(cmd)
 		cmd.ValidArgsFunction = func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
-			fds, err2 := reflectFileDesc(args)
-			if err2 != nil {
-				err = err2
+			// Completion runs after cmd.ExecuteContext may have replaced the context that holds the settings.
+			restoreSettings(cmd, s)
+			fds, err := reflectFileDesc(args)
+			if err != nil {
 				return nil, cobra.ShellCompDirectiveNoFileComp
 			}
-			var opts []string
-			err2 = CommandFromFileDescriptors(cmd, fds...)
-			if err2 != nil {
-				err = err2
+			if err := CommandFromFileDescriptors(cmd, fds...); err != nil {
 				return nil, cobra.ShellCompDirectiveNoFileComp
 			}
-			return opts, cobra.ShellCompDirectiveNoFileComp
-		}
-		fds, err := reflectFileDesc(args[1:])
-		if err != nil {
-			return err
-		}
-		if err = persistentFlags(cmd, ""); err != nil {
-			return err
-		}
-		err = CommandFromFileDescriptors(cmd, fds...)
-		if err != nil {
-			return err
+			return nil, cobra.ShellCompDirectiveNoFileComp
 		}
-		return nil
+		return generate(cmd, func() error {
+			fds, err := reflectFileDesc(args[1:])
+			if err != nil {
+				return err
+			}
+			if err = persistentFlags(cmd, ""); err != nil {
+				return err
+			}
+			return CommandFromFileDescriptors(cmd, fds...)
+		})
 	}
 }
 
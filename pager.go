@@ -0,0 +1,97 @@
+package grpctl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// pager buffers output, and writes it through a pager command when it has more lines than the terminal.
+type pager struct {
+	buf     bytes.Buffer
+	out     io.Writer
+	command string
+	height  int
+}
+
+func (p *pager) Write(b []byte) (int, error) {
+	return p.buf.Write(b)
+}
+
+// Close writes the buffered output to out, through the pager if it doesn't fit the terminal.
+func (p *pager) Close(cmd *cobra.Command) error {
+	if bytes.Count(p.buf.Bytes(), []byte("\n")) < p.height {
+		_, err := p.out.Write(p.buf.Bytes())
+		return err
+	}
+	args := strings.Fields(p.command)
+	c := exec.CommandContext(cmd.Context(), args[0], args[1:]...) //nolint:gosec // the pager is chosen by the user
+	c.Stdin = &p.buf
+	c.Stdout = p.out
+	c.Stderr = cmd.ErrOrStderr()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		// As in git, less quits if the output fits the screen after all and keeps colors.
+		c.Env = append(os.Environ(), "LESS=FRX")
+	}
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("pager %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// pagerCommand returns $PAGER, or less, or an empty string if $PAGER is set to nothing, cat or a missing command.
+func pagerCommand() string {
+	command, ok := os.LookupEnv("PAGER")
+	if !ok {
+		command = "less"
+	}
+	args := strings.Fields(command)
+	if len(args) == 0 || args[0] == "cat" {
+		return ""
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return ""
+	}
+	return command
+}
+
+// useColor returns whether output to w is colored for a --color mode of auto, always or never.
+// In auto mode, output is only colored on terminals, unless NO_COLOR is set.
+func useColor(mode string, w io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		width, _ := terminalSize(w)
+		return width > 0, nil
+	}
+	return false, fmt.Errorf("unknown color mode %q, expected one of %s", mode, strings.Join(colorModes(), ", "))
+}
+
+func colorModes() []string {
+	return []string{"auto", "always", "never"}
+}
+
+// terminalSize returns the width and height of the terminal w writes to, or zeros if it isn't a terminal.
+func terminalSize(w io.Writer) (int, int) {
+	f, ok := w.(*os.File)
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0, 0
+	}
+	width, height, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0, 0
+	}
+	return width, height
+}